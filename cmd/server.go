@@ -43,6 +43,10 @@ func main() {
 				for _, record := range result {
 					fmt.Printf("%-10s|", record.Field.Name)
 					for _, data := range record.Data {
+						// 空值显示为NULL，与空字符串区分开
+						if data == parser.NullValue {
+							data = "NULL"
+						}
 						fmt.Printf("%-10s\t|", data)
 					}
 					fmt.Println()
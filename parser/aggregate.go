@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// 处理GROUP BY子句、聚集函数和HAVING子句
+// 得到的中间结果中每个分组是一行，包括分组的列和查询的列、HAVING子句、ORDER BY子句中用到的所有聚集函数
+func groupResultSet(set *resultSet, sql Sql) (result *resultSet, err error) {
+	// 收集查询的列、HAVING子句和ORDER BY子句中用到的聚集函数，同名的只计算一次
+	var expressions []*Expression
+	expressions = append(expressions, sql.FieldExpressions...)
+	expressions = append(expressions, conditionExpressions(sql.HavingConditions)...)
+	expressions = append(expressions, sql.OrderByExpressions...)
+	var aggregates []Aggregate
+	for _, expression := range expressions {
+		for _, aggregate := range expression.aggregates() {
+			aggregates = appendAggregate(aggregates, aggregate)
+		}
+	}
+	// 既没有分组也没有聚集函数，不需要处理
+	if len(sql.GroupByFields) == 0 && len(aggregates) == 0 {
+		if len(sql.HavingConditions) > 0 {
+			return nil, fmt.Errorf("at HAVING: HAVING clause requires GROUP BY or aggregate functions")
+		}
+		return set, nil
+	}
+
+	// 找到分组的列
+	var groupIndexes []int
+	for _, fieldName := range sql.GroupByFields {
+		index, err := set.fieldIndex(fieldName)
+		if err != nil {
+			return nil, fmt.Errorf("at GROUP BY: %v", err)
+		}
+		groupIndexes = append(groupIndexes, index)
+	}
+	// 查询的列中聚集函数以外用到的列必须出现在GROUP BY子句中
+	for _, expression := range sql.FieldExpressions {
+		for _, fieldName := range expression.fields(false) {
+			fieldIndex, err := set.fieldIndex(fieldName)
+			if err != nil {
+				return nil, fmt.Errorf("at SELECT: %v", err)
+			}
+			if !containsIndex(groupIndexes, fieldIndex) {
+				return nil, fmt.Errorf("at SELECT: field %s must appear in GROUP BY or be used in an aggregate function", fieldName)
+			}
+		}
+	}
+
+	// 按分组的列的值分组，分组按照第一次出现的顺序排列
+	var keys []string
+	groups := map[string][][]string{}
+	for _, row := range set.rows {
+		var values []string
+		for _, index := range groupIndexes {
+			values = append(values, row[index])
+		}
+		key := joinValues(values)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+	// 没有GROUP BY子句时，所有行是一个分组，即使一行数据都没有
+	if len(groupIndexes) == 0 && len(keys) == 0 {
+		keys = append(keys, "")
+		groups[""] = [][]string{}
+	}
+
+	// 生成分组后的中间结果：先是分组的列，然后是聚集函数
+	result = &resultSet{outer: set.outer}
+	for _, index := range groupIndexes {
+		result.fields = append(result.fields, set.fields[index])
+	}
+	for _, aggregate := range aggregates {
+		dataType, err := aggregateDataType(aggregate, set)
+		if err != nil {
+			return nil, err
+		}
+		result.fields = append(result.fields, resultField{field: Field{Name: aggregate.Name(), DataType: dataType}})
+	}
+	for _, key := range keys {
+		rows := groups[key]
+		var row []string
+		for _, index := range groupIndexes {
+			row = append(row, rows[0][index])
+		}
+		for _, aggregate := range aggregates {
+			value, err := computeAggregate(aggregate, set, rows)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, value)
+		}
+		result.rows = append(result.rows, row)
+	}
+
+	// 用HAVING子句筛选分组
+	return result.filter(sql.HavingConditions, sql.HavingOperators)
+}
+
+// 把聚集函数加入列表，不是聚集函数或者已经存在同名的聚集函数时不加入
+func appendAggregate(aggregates []Aggregate, aggregate Aggregate) []Aggregate {
+	if aggregate.Function == NoAggregate {
+		return aggregates
+	}
+	for _, exist := range aggregates {
+		if exist.Name() == aggregate.Name() {
+			return aggregates
+		}
+	}
+	return append(aggregates, aggregate)
+}
+
+// 判断下标是否在数组中
+func containsIndex(indexes []int, index int) bool {
+	for _, i := range indexes {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// 条件中的所有表达式，包括括号中的一组条件中的表达式
+func conditionExpressions(conditions []Condition) (expressions []*Expression) {
+	for _, condition := range conditions {
+		expressions = append(expressions, condition.Expression1, condition.Expression2)
+		expressions = append(expressions, conditionExpressions(condition.Group)...)
+	}
+	return expressions
+}
+
+// 聚集函数结果的数据类型：COUNT为SMALLINT，AVG为DOUBLE（参数为DECIMAL时为DECIMAL），其他与参数的类型相同
+// SUM和AVG只能作用在数值类型上
+func aggregateDataType(aggregate Aggregate, set *resultSet) (dataType DataType, err error) {
+	if aggregate.Function == CountAggregate {
+		return SmallInt, nil
+	}
+	dataType, err = aggregate.Expression.dataType(set)
+	if err != nil {
+		return UnknownDataType, fmt.Errorf("at %s: %v", aggregate.Name(), err)
+	}
+	switch aggregate.Function {
+	case SumAggregate, AvgAggregate:
+		if !numberArgument.accepts(dataType) {
+			return UnknownDataType, fmt.Errorf("at %s: cannot apply %s to %s field %s",
+				aggregate.Name(), AggregateFunctionString[aggregate.Function], DataTypeString[dataType], aggregate.Field)
+		}
+		if aggregate.Function == AvgAggregate && dataType != Decimal {
+			return Double, nil
+		}
+	}
+	return dataType, nil
+}
+
+// 计算一个分组上的聚集函数，空值不参与计算；除COUNT外，没有可以计算的值时结果为空值
+func computeAggregate(aggregate Aggregate, set *resultSet, rows [][]string) (value string, err error) {
+	// COUNT(*)：分组中的行数
+	if aggregate.Field == "*" {
+		return strconv.Itoa(len(rows)), nil
+	}
+	dataType, err := aggregateDataType(aggregate, set)
+	if err != nil {
+		return "", err
+	}
+	// 在每一行上计算参数，取出所有的非空值，DISTINCT时去掉重复的值
+	var values []string
+	seen := map[string]bool{}
+	for _, row := range rows {
+		v, _, err := aggregate.Expression.evaluate(set.rowGetter(row))
+		if err != nil {
+			return "", fmt.Errorf("at %s: %v", aggregate.Name(), err)
+		}
+		if v == NullValue {
+			continue
+		}
+		if aggregate.Distinct {
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+		}
+		values = append(values, v)
+	}
+
+	switch aggregate.Function {
+	case CountAggregate:
+		return strconv.Itoa(len(values)), nil
+	case SumAggregate, AvgAggregate:
+		if len(values) == 0 {
+			return NullValue, nil
+		}
+		if dataType == Decimal || isIntegerType(dataType) {
+			return exactSum(aggregate, values, dataType)
+		}
+		sum := 0.0
+		for _, v := range values {
+			number, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return "", fmt.Errorf("at %s: %s is not a number", aggregate.Name(), v)
+			}
+			sum += number
+		}
+		if aggregate.Function == AvgAggregate {
+			return strconv.FormatFloat(sum/float64(len(values)), 'f', -1, 64), nil
+		}
+		return formatNumber(sum, dataType), nil
+	case MaxAggregate, MinAggregate:
+		if len(values) == 0 {
+			return NullValue, nil
+		}
+		value = values[0]
+		for _, v := range values[1:] {
+			cmp, err := compareValues(v, value, dataType)
+			if err != nil {
+				return "", err
+			}
+			if (aggregate.Function == MaxAggregate && cmp > 0) || (aggregate.Function == MinAggregate && cmp < 0) {
+				value = v
+			}
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("at SELECT: unknown aggregate function")
+	}
+}
+
+// 按十进制精确计算整数和DECIMAL的SUM和AVG
+// SUM的小数位数为参数中最大的小数位数，DECIMAL的AVG再多保留4位小数，整数的AVG为DOUBLE
+func exactSum(aggregate Aggregate, values []string, dataType DataType) (value string, err error) {
+	sum, scale := new(big.Rat), 0
+	for _, v := range values {
+		number, err := parseDecimal(v)
+		if err != nil {
+			return "", fmt.Errorf("at %s: %s is not a number", aggregate.Name(), v)
+		}
+		sum.Add(sum, number)
+		scale = max(scale, decimalScale(v))
+	}
+	if aggregate.Function == AvgAggregate {
+		average := new(big.Rat).Quo(sum, new(big.Rat).SetInt64(int64(len(values))))
+		if dataType == Decimal {
+			return average.FloatString(scale + 4), nil
+		}
+		number, _ := average.Float64()
+		return formatNumber(number, Double), nil
+	}
+	if dataType == BigInt && (!sum.IsInt() || !sum.Num().IsInt64()) {
+		return "", fmt.Errorf("at %s: BIGINT out of range", aggregate.Name())
+	}
+	return sum.FloatString(scale), nil
+}
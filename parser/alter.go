@@ -0,0 +1,431 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 修改表定义的处理器，返回受影响的行数
+func handleAlterTable(sql Sql) (rows int, err error) {
+	table, err := readTableJson(sql.Tables[0])
+	if err != nil {
+		return 0, fmt.Errorf("at ALTER TABLE: %v", err)
+	}
+	if table.Definition != "" {
+		return 0, fmt.Errorf("at ALTER TABLE: %s is a materialized view", table.Name)
+	}
+	switch sql.AlterAction {
+	case AddColumn:
+		rows, err = alterAddColumns(table, sql.CreateFields)
+	case DropColumn:
+		rows, err = alterDropColumn(table, sql.Fields[0])
+	case RenameColumn:
+		rows, err = alterRenameColumn(table, sql.Fields[0], sql.Fields[1])
+	case AlterColumnType:
+		rows, err = alterColumnType(table, sql.CreateFields[0])
+	case AddConstraint:
+		rows, err = alterAddConstraint(table, sql.TableConstraints[0])
+	case DropConstraint:
+		rows, err = alterDropConstraint(table, sql.TableConstraints[0].Name)
+	default:
+		return 0, fmt.Errorf("at ALTER TABLE: unknown action")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err = writeTableJson(table); err != nil {
+		panic(err)
+	}
+	return rows, nil
+}
+
+// 找到表中的列，不存在时返回-1
+func tableFieldIndex(table *TableJson, fieldName string) int {
+	for index, field := range table.Fields {
+		if field.Name == fieldName {
+			return index
+		}
+	}
+	return -1
+}
+
+// 增加列，已有的行中新的列都是默认值，没有默认值时为空值
+func alterAddColumns(table *TableJson, fields []Field) (rows int, err error) {
+	rowCount := tableRowCount(table)
+	for _, field := range fields {
+		if tableFieldIndex(table, field.Name) >= 0 {
+			return 0, fmt.Errorf("at ADD COLUMN: field %s already exists in table %s", field.Name, table.Name)
+		}
+		newField := FieldJson{
+			Name:       field.Name,
+			DataType:   field.DataType,
+			DataLength: field.DataLength,
+			Scale:      field.Scale,
+			NotNull:    field.NotNull,
+			Unique:     field.Unique,
+			PrimaryKey: field.PrimaryKey,
+			Default:    field.Default,
+			Data:       make([]string, rowCount),
+		}
+		// 已有的行中新的列都是默认值
+		value, err := defaultValue(newField)
+		if err != nil {
+			return 0, fmt.Errorf("at ADD COLUMN: %v", err)
+		}
+		for row := range newField.Data {
+			newField.Data[row] = value
+		}
+		if newField.PrimaryKey && primaryKeyFields(table) != nil {
+			return 0, fmt.Errorf("at ADD COLUMN: table %s already has a primary key (%s)", table.Name, strings.Join(primaryKeyFields(table), ", "))
+		}
+		// 已有的行中新的列没有默认值时都是空值，不能满足非空约束；所有的行都是相同的值，不能满足唯一约束
+		if rowCount > 0 && (newField.NotNull || newField.PrimaryKey) && value == NullValue {
+			return 0, fmt.Errorf("at ADD COLUMN: field %s cannot be NOT NULL because table %s is not empty", field.Name, table.Name)
+		}
+		if rowCount > 1 && (newField.Unique || newField.PrimaryKey) {
+			return 0, fmt.Errorf("at ADD COLUMN: field %s cannot be UNIQUE because table %s has more than one row", field.Name, table.Name)
+		}
+		table.Fields = append(table.Fields, newField)
+		if err = recordFieldKeyConstraints(table, field, "ADD COLUMN"); err != nil {
+			return 0, err
+		}
+		if len(field.CheckConditions) > 0 {
+			err = addCheckConstraint(table, "", field.CheckConditions, field.CheckConditionsOperator, "ADD COLUMN")
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return rowCount, nil
+}
+
+// 删除列，同时删除这一列上的索引和约束
+func alterDropColumn(table *TableJson, fieldName string) (rows int, err error) {
+	index := tableFieldIndex(table, fieldName)
+	if index < 0 {
+		return 0, fmt.Errorf("at DROP COLUMN: unknown field %s in table %s", fieldName, table.Name)
+	}
+	if len(table.Fields) == 1 {
+		return 0, fmt.Errorf("at DROP COLUMN: cannot drop the only column of table %s", table.Name)
+	}
+	// 被其他表的外键参照的列不能删除
+	referencing, err := referencingForeignKeys(table.Name, fieldName)
+	if err != nil {
+		return 0, err
+	}
+	if len(referencing) > 0 {
+		return 0, fmt.Errorf("at DROP COLUMN: foreign key %s references %s.%s", referencing[0], table.Name, fieldName)
+	}
+	rows = tableRowCount(table)
+	table.Fields = append(table.Fields[:index], table.Fields[index+1:]...)
+	// 包含这一列的约束也一起删除
+	var constraints []ConstraintJson
+	for _, constraint := range table.Constraints {
+		if !containsString(constraint.Fields, fieldName) {
+			constraints = append(constraints, constraint)
+		}
+	}
+	table.Constraints = constraints
+	files, err := getIndexFiles("", table.Name)
+	if err != nil {
+		return 0, err
+	}
+	for _, fileName := range files {
+		if strings.HasSuffix(fileName, "_"+fieldName+".json") {
+			if err = os.Remove("./file/" + fileName); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// 修改列名，同时修改约束、索引和参照这一列的外键中的列名
+func alterRenameColumn(table *TableJson, fieldName string, newName string) (rows int, err error) {
+	index := tableFieldIndex(table, fieldName)
+	if index < 0 {
+		return 0, fmt.Errorf("at RENAME COLUMN: unknown field %s in table %s", fieldName, table.Name)
+	}
+	if tableFieldIndex(table, newName) >= 0 {
+		return 0, fmt.Errorf("at RENAME COLUMN: field %s already exists in table %s", newName, table.Name)
+	}
+	table.Fields[index].Name = newName
+	for _, constraint := range table.Constraints {
+		for i, name := range constraint.Fields {
+			if name == fieldName {
+				constraint.Fields[i] = newName
+			}
+		}
+		// Check约束条件中的列名：两个操作数和两个操作数的表达式中都可能引用这一列
+		for i := range constraint.Conditions {
+			condition := &constraint.Conditions[i]
+			if condition.Operand1IsField {
+				condition.Operand1 = renameFieldReference(condition.Operand1, table.Name, fieldName, newName)
+			}
+			if condition.Operand2IsField {
+				condition.Operand2 = renameFieldReference(condition.Operand2, table.Name, fieldName, newName)
+			}
+			condition.Expression1.renameField(table.Name, fieldName, newName)
+			condition.Expression2.renameField(table.Name, fieldName, newName)
+		}
+	}
+	// 本表中参照本表这一列的外键
+	for i, field := range table.Fields {
+		if field.ForeignKey && field.ForeignKeyTable == table.Name && field.ForeignKeyColumn == fieldName {
+			table.Fields[i].ForeignKeyColumn = newName
+		}
+	}
+	// 其他表中参照这一列的外键
+	referencing, err := referencingForeignKeys(table.Name, fieldName)
+	if err != nil {
+		return 0, err
+	}
+	for _, reference := range referencing {
+		tableName := strings.Split(reference, ".")[0]
+		if tableName == table.Name {
+			continue
+		}
+		other, err := readTableJson(tableName)
+		if err != nil {
+			return 0, err
+		}
+		for i, field := range other.Fields {
+			if field.ForeignKey && field.ForeignKeyTable == table.Name && field.ForeignKeyColumn == fieldName {
+				other.Fields[i].ForeignKeyColumn = newName
+			}
+		}
+		for i, constraint := range other.Constraints {
+			if constraint.ForeignKeyTable == table.Name && constraint.ForeignKeyColumn == fieldName {
+				other.Constraints[i].ForeignKeyColumn = newName
+			}
+		}
+		if err = writeTableJson(other); err != nil {
+			panic(err)
+		}
+	}
+	// 索引文件名中的列名
+	files, err := getIndexFiles("", table.Name)
+	if err != nil {
+		return 0, err
+	}
+	for _, fileName := range files {
+		if strings.HasSuffix(fileName, "_"+fieldName+".json") {
+			newFileName := strings.TrimSuffix(fileName, fieldName+".json") + newName + ".json"
+			if err = os.Rename("./file/"+fileName, "./file/"+newFileName); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return 0, nil
+}
+
+// 修改列的数据类型，已有的数据转换为新的类型，不能转换时报错
+func alterColumnType(table *TableJson, field Field) (rows int, err error) {
+	index := tableFieldIndex(table, field.Name)
+	if index < 0 {
+		return 0, fmt.Errorf("at ALTER COLUMN: unknown field %s in table %s", field.Name, table.Name)
+	}
+	if len(field.Constraint) > 0 {
+		return 0, fmt.Errorf("at ALTER COLUMN: use ADD CONSTRAINT to add constraints")
+	}
+	// 列的默认值也必须符合新的类型
+	converted := table.Fields[index]
+	converted.DataType, converted.DataLength, converted.Scale = field.DataType, field.DataLength, field.Scale
+	if _, err = defaultValue(converted); err != nil {
+		return 0, fmt.Errorf("at ALTER COLUMN: %v", err)
+	}
+	data := make([]string, len(table.Fields[index].Data))
+	for row, value := range table.Fields[index].Data {
+		data[row], err = convertValue(value, field.DataType, field.DataLength, field.Scale)
+		if err != nil {
+			return 0, fmt.Errorf("at ALTER COLUMN: cannot convert field %s: %v", field.Name, err)
+		}
+		if data[row] != value {
+			rows++
+		}
+	}
+	table.Fields[index].DataType = field.DataType
+	table.Fields[index].DataLength = field.DataLength
+	table.Fields[index].Scale = field.Scale
+	table.Fields[index].Data = data
+	return rows, nil
+}
+
+// 增加有名称的约束，已有的数据必须满足这个约束
+func alterAddConstraint(table *TableJson, constraint TableConstraint) (rows int, err error) {
+	for _, existing := range table.Constraints {
+		if existing.Name == constraint.Name {
+			return 0, fmt.Errorf("at ADD CONSTRAINT: constraint %s already exists in table %s", constraint.Name, table.Name)
+		}
+	}
+	// Check约束的条件可以引用多个列
+	if constraint.ConstraintType == Check {
+		return 0, addCheckConstraint(table, constraint.Name, constraint.Conditions, constraint.ConditionOperators, "ADD CONSTRAINT")
+	}
+	// 多列的主键和唯一约束作为表级约束保存
+	if len(constraint.Fields) > 1 && (constraint.ConstraintType == PrimaryKey || constraint.ConstraintType == Unique) {
+		return 0, addKeyConstraint(table, constraint.Name, constraint.ConstraintType, constraint.Fields, "ADD CONSTRAINT")
+	}
+	if len(constraint.Fields) != 1 {
+		return 0, fmt.Errorf("at ADD CONSTRAINT: constraint %s must have exactly one column", constraint.Name)
+	}
+	fieldName := constraint.Fields[0]
+	index := tableFieldIndex(table, fieldName)
+	if index < 0 {
+		return 0, fmt.Errorf("at ADD CONSTRAINT: unknown field %s in table %s", fieldName, table.Name)
+	}
+	field := &table.Fields[index]
+	switch constraint.ConstraintType {
+	case PrimaryKey:
+		if primaryKey := primaryKeyFields(table); primaryKey != nil {
+			return 0, fmt.Errorf("at ADD CONSTRAINT: table %s already has a primary key (%s)", table.Name, strings.Join(primaryKey, ", "))
+		}
+		for row := 0; row < tableRowCount(table); row++ {
+			if row >= len(field.Data) || field.Data[row] == NullValue {
+				return 0, fmt.Errorf("at ADD CONSTRAINT: field %s contains null values", fieldName)
+			}
+		}
+		if err = checkDistinctValues(field); err != nil {
+			return 0, err
+		}
+		field.PrimaryKey = true
+	case Unique:
+		if err = checkDistinctValues(field); err != nil {
+			return 0, err
+		}
+		field.Unique = true
+	case ForeignKey:
+		referenced, err := readTableJson(constraint.ReferenceTable)
+		if err != nil {
+			return 0, fmt.Errorf("at ADD CONSTRAINT: %v", err)
+		}
+		if referenced.Name == table.Name {
+			referenced = table
+		}
+		referencedIndex := tableFieldIndex(referenced, constraint.ReferenceField)
+		if referencedIndex < 0 {
+			return 0, fmt.Errorf("at ADD CONSTRAINT: unknown field %s in table %s", constraint.ReferenceField, referenced.Name)
+		}
+		if !isKeyField(referenced, constraint.ReferenceField) {
+			return 0, fmt.Errorf("at ADD CONSTRAINT: referenced field %s(%s) is not a PRIMARY KEY or UNIQUE field",
+				referenced.Name, constraint.ReferenceField)
+		}
+		if err = checkForeignKeyType(*field, referenced.Fields[referencedIndex], referenced.Name); err != nil {
+			return 0, fmt.Errorf("at ADD CONSTRAINT: %v", err)
+		}
+		// 已有的值都必须在被参照列中出现，比较前转换为被参照列的类型
+		for _, value := range field.Data {
+			if value != NullValue && !containsString(referenced.Fields[referencedIndex].Data, referencedValue(value, *field, referenced.Fields[referencedIndex])) {
+				return 0, fmt.Errorf("at ADD CONSTRAINT: value %s of field %s is not in %s(%s)",
+					value, fieldName, referenced.Name, constraint.ReferenceField)
+			}
+		}
+		field.ForeignKey = true
+		field.ForeignKeyTable = referenced.Name
+		field.ForeignKeyColumn = constraint.ReferenceField
+		field.OnDelete = constraint.OnDelete
+		field.OnUpdate = constraint.OnUpdate
+	default:
+		return 0, fmt.Errorf("at ADD CONSTRAINT: unknown constraint type")
+	}
+	table.Constraints = append(table.Constraints, ConstraintJson{
+		Name:             constraint.Name,
+		ConstraintType:   constraint.ConstraintType,
+		Fields:           constraint.Fields,
+		ForeignKeyTable:  constraint.ReferenceTable,
+		ForeignKeyColumn: constraint.ReferenceField,
+	})
+	return 0, nil
+}
+
+// 删除有名称的约束
+func alterDropConstraint(table *TableJson, name string) (rows int, err error) {
+	dropped := -1
+	for index, constraint := range table.Constraints {
+		if constraint.Name == name {
+			dropped = index
+		}
+	}
+	var constraint ConstraintJson
+	if dropped >= 0 {
+		constraint = table.Constraints[dropped]
+		table.Constraints = append(table.Constraints[:dropped], table.Constraints[dropped+1:]...)
+	} else {
+		// 之前创建的表中列级的主键和唯一约束没有记录约束名，按默认名称删除
+		for _, key := range keyConstraints(table) {
+			if key.Name == name && len(key.Fields) == 1 {
+				constraint, dropped = key, 0
+			}
+		}
+		if dropped < 0 {
+			return 0, fmt.Errorf("at DROP CONSTRAINT: unknown constraint %s in table %s", name, table.Name)
+		}
+	}
+	// 多列的约束只保存在表级约束中，列的定义中没有记录
+	for _, fieldName := range constraint.Fields {
+		fieldIndex := tableFieldIndex(table, fieldName)
+		if fieldIndex < 0 || len(constraint.Fields) > 1 {
+			continue
+		}
+		field := &table.Fields[fieldIndex]
+		switch constraint.ConstraintType {
+		case PrimaryKey:
+			field.PrimaryKey = false
+		case Unique:
+			field.Unique = false
+		case ForeignKey:
+			field.ForeignKey = false
+			field.ForeignKeyTable = ""
+			field.ForeignKeyColumn = ""
+			field.OnDelete = NoAction
+			field.OnUpdate = NoAction
+		}
+		// 被外键参照的列必须仍然是主键或者唯一约束
+		if (constraint.ConstraintType == PrimaryKey || constraint.ConstraintType == Unique) && !isKeyField(table, fieldName) {
+			referencing, err := referencingForeignKeys(table.Name, fieldName)
+			if err != nil {
+				return 0, err
+			}
+			if len(referencing) > 0 {
+				return 0, fmt.Errorf("at DROP CONSTRAINT: foreign key %s references %s.%s", referencing[0], table.Name, fieldName)
+			}
+		}
+	}
+	return 0, nil
+}
+
+// 检查一列中的非空值是否各不相同
+func checkDistinctValues(field *FieldJson) error {
+	seen := map[string]bool{}
+	for _, value := range field.Data {
+		if value == NullValue {
+			continue
+		}
+		if seen[value] {
+			return fmt.Errorf("at ADD CONSTRAINT: field %s contains duplicate value %s", field.Name, value)
+		}
+		seen[value] = true
+	}
+	return nil
+}
+
+// 找到参照某个表的某一列的外键，返回"表名.列名"
+func referencingForeignKeys(tableName string, fieldName string) (references []string, err error) {
+	tables, _, _, _, err := getFilesForHelpDataBase()
+	if err != nil {
+		return nil, err
+	}
+	for _, fileName := range tables {
+		table, err := readTableJson(strings.TrimSuffix(fileName, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range table.Fields {
+			if field.ForeignKey && field.ForeignKeyTable == tableName && field.ForeignKeyColumn == fieldName {
+				references = append(references, table.Name+"."+field.Name)
+			}
+		}
+	}
+	return references, nil
+}
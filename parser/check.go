@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// 把Check约束加入表定义，name为空时生成默认的约束名
+// 约束条件中的列必须都在表中，表中已有的数据必须满足这个约束
+func addCheckConstraint(table *TableJson, name string, conditions []Condition, operators []ConditionOperator, operation string) error {
+	if err := tableSchema(table).checkConditions(conditions); err != nil {
+		return fmt.Errorf("at %s: %v", operation, err)
+	}
+	fields := checkConstraintFields(conditions)
+	if name == "" {
+		name = checkConstraintName(table, fields)
+	}
+	constraint := ConstraintJson{
+		Name:               name,
+		ConstraintType:     Check,
+		Fields:             fields,
+		Conditions:         conditions,
+		ConditionOperators: operators,
+	}
+	for row := 0; row < tableRowCount(table); row++ {
+		matched, err := matchCheckConstraint(constraint, tableRowGetter(table, row))
+		if err != nil {
+			return fmt.Errorf("at %s: %v", operation, err)
+		}
+		if !matched {
+			return fmt.Errorf("at %s: existing rows of table %s violate CHECK constraint %s", operation, table.Name, name)
+		}
+	}
+	table.Constraints = append(table.Constraints, constraint)
+	return nil
+}
+
+// Check约束的默认名称：表名_列名_check，和已有的约束重名时在后面加上序号
+func checkConstraintName(table *TableJson, fields []string) string {
+	base := table.Name + "_check"
+	if len(fields) > 0 {
+		base = table.Name + "_" + fields[0] + "_check"
+	}
+	name := base
+	for number := 1; ; number++ {
+		exists := false
+		for _, constraint := range table.Constraints {
+			if constraint.Name == name {
+				exists = true
+			}
+		}
+		if !exists {
+			return name
+		}
+		name = base + strconv.Itoa(number)
+	}
+}
+
+// Check约束条件中引用的所有列，每一列只出现一次
+func checkConstraintFields(conditions []Condition) (fields []string) {
+	for _, condition := range conditions {
+		if len(condition.Group) > 0 {
+			for _, field := range checkConstraintFields(condition.Group) {
+				if !containsString(fields, field) {
+					fields = append(fields, field)
+				}
+			}
+			continue
+		}
+		operands := conditionExpression(condition.Expression1, condition.Operand1, condition.Operand1IsField).fields(true)
+		if condition.Operand2IsField || condition.Expression2 != nil {
+			operands = append(operands, conditionExpression(condition.Expression2, condition.Operand2, condition.Operand2IsField).fields(true)...)
+		}
+		for _, field := range operands {
+			if !containsString(fields, field) {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// 判断一行数据是否满足Check约束：只有条件的结果为假时才违反约束，有空值导致结果未知时不算违反约束
+func matchCheckConstraint(constraint ConstraintJson, getValue valueGetter) (result bool, err error) {
+	value, err := conditionsTruth(constraint.Conditions, constraint.ConditionOperators, getValue)
+	return value != truthFalse, err
+}
+
+// 检查插入或者更新后的一行数据是否满足表中的所有Check约束
+func checkConstraints(table *TableJson, operation string, getValue valueGetter) error {
+	for _, constraint := range table.Constraints {
+		if constraint.ConstraintType != Check {
+			continue
+		}
+		matched, err := matchCheckConstraint(constraint, getValue)
+		if err != nil {
+			return fmt.Errorf("at %s: %v", operation, err)
+		}
+		if !matched {
+			return fmt.Errorf("at %s: row violates CHECK constraint %s", operation, constraint.Name)
+		}
+	}
+	return nil
+}
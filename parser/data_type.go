@@ -1,6 +1,19 @@
 package parser
 
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
 // 基本数据类型定义
+// 数据类型的值保存在表文件中，新的类型只能加在最后
 type DataType int
 
 const (
@@ -14,6 +27,22 @@ const (
 	DateTime
 	// 变长字符串类型，对应Go的string
 	Varchar
+	// 布尔类型，存储为TRUE或者FALSE
+	Boolean
+	// 有符号64位整数类型，对应Go的int64
+	BigInt
+	// 定点数类型DECIMAL(p,s)：一共p位有效数字，其中s位小数，按十进制精确计算，存储为保留s位小数的字符串
+	Decimal
+	// 定长字符串类型CHAR(n)，不足n个字符时在后面补空格，比较时忽略末尾的空格
+	Char
+	// 日期类型，格式为YYYY-MM-DD
+	Date
+	// 时间类型，格式为HH:MM:SS
+	Time
+	// 不限长度的字符串类型
+	Text
+	// 二进制数据类型，存储为\x开头的十六进制字符串，可以保存任意的字节
+	Blob
 )
 
 var DataTypeString = []string{
@@ -22,4 +51,200 @@ var DataTypeString = []string{
 	"DOUBLE",
 	"DATETIME",
 	"VARCHAR",
+	"BOOLEAN",
+	"BIGINT",
+	"DECIMAL",
+	"CHAR",
+	"DATE",
+	"TIME",
+	"TEXT",
+	"BLOB",
+}
+
+// 日期类型和时间类型的存储格式
+const (
+	dateLayout = "2006-01-02"
+	timeLayout = "15:04:05"
+)
+
+// DECIMAL没有给出精度时的默认精度，小数位数默认为0，与DECIMAL(10,0)相同
+const (
+	defaultDecimalPrecision = 10
+	maxDecimalPrecision     = 65
+)
+
+// 十进制数的格式：可以有符号、小数点和指数，例如-1.5、.5、1e3
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d{1,3})?$`)
+
+// 空值NULL在内存中的表示，与空字符串不同，在表文件中存储为JSON的null
+const NullValue = "\x00"
+
+// 判断是否为数值类型
+func isNumberType(dataType DataType) bool {
+	return dataType == SmallInt || dataType == BigInt || dataType == Double || dataType == Decimal
+}
+
+// 判断是否为整数类型
+func isIntegerType(dataType DataType) bool {
+	return dataType == SmallInt || dataType == BigInt
+}
+
+// 判断是否为字符串类型
+func isStringType(dataType DataType) bool {
+	return dataType == Varchar || dataType == Char || dataType == Text
+}
+
+// 判断是否为日期时间类型
+func isDateTimeType(dataType DataType) bool {
+	return dataType == DateTime || dataType == Date || dataType == Time
+}
+
+// 列的数据类型的完整写法，例如VARCHAR(20)、DECIMAL(10,2)，用于显示表结构
+func dataTypeName(dataType DataType, dataLength int, scale int) string {
+	switch {
+	case dataType == Decimal:
+		return fmt.Sprintf("%s(%d,%d)", DataTypeString[dataType], dataLength, scale)
+	case (dataType == Varchar || dataType == Char) && dataLength > 0:
+		return fmt.Sprintf("%s(%d)", DataTypeString[dataType], dataLength)
+	}
+	return DataTypeString[dataType]
+}
+
+// 把十进制数的字符串转换为精确的有理数
+func parseDecimal(value string) (number *big.Rat, err error) {
+	if !decimalPattern.MatchString(value) {
+		return nil, fmt.Errorf("%s is not a number", value)
+	}
+	number, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a number", value)
+	}
+	return number, nil
+}
+
+// 十进制数的字符串中小数点后的位数
+func decimalScale(value string) int {
+	value = strings.SplitN(strings.ToLower(value), "e", 2)[0]
+	if dot := strings.Index(value, "."); dot >= 0 {
+		return len(value) - dot - 1
+	}
+	return 0
+}
+
+// 把布尔值的各种写法转换为TRUE或者FALSE
+func toBoolean(value string) (converted string, err error) {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "TRUE", "T", "YES", "Y", "ON", "1":
+		return "TRUE", nil
+	case "FALSE", "F", "NO", "N", "OFF", "0":
+		return "FALSE", nil
+	}
+	return "", fmt.Errorf("'%s' is not a valid BOOLEAN", value)
+}
+
+// 把值转换为数据类型的规范形式，值不符合数据类型时报错，空值不需要转换
+// 例如SMALLINT的85.0转换为85，DATETIME只有日期时补齐时间部分，DECIMAL(5,2)的1.5转换为1.50
+// dataLength是字符串的长度或者DECIMAL的精度，scale是DECIMAL的小数位数
+func convertValue(value string, dataType DataType, dataLength int, scale int) (converted string, err error) {
+	if value == NullValue {
+		return value, nil
+	}
+	switch dataType {
+	case SmallInt:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || number != math.Trunc(number) || number < math.MinInt32 || number > math.MaxInt32 {
+			return "", fmt.Errorf("'%s' is not a valid SMALLINT", value)
+		}
+		return strconv.FormatInt(int64(number), 10), nil
+	case BigInt:
+		// 超出float64精度的整数也要精确转换，所以按十进制数解析
+		number, err := parseDecimal(value)
+		if err != nil || !number.IsInt() || !number.Num().IsInt64() {
+			return "", fmt.Errorf("'%s' is not a valid BIGINT", value)
+		}
+		return number.Num().String(), nil
+	case Double:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return "", fmt.Errorf("'%s' is not a valid DOUBLE", value)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	case Decimal:
+		number, err := parseDecimal(value)
+		if err != nil {
+			return "", fmt.Errorf("'%s' is not a valid DECIMAL", value)
+		}
+		// 多出的小数位四舍五入，整数部分的位数不能超过精度减去小数位数
+		converted = number.FloatString(scale)
+		integer := strings.TrimLeft(strings.SplitN(strings.TrimPrefix(converted, "-"), ".", 2)[0], "0")
+		if dataLength > 0 && len(integer) > dataLength-scale {
+			return "", fmt.Errorf("'%s' is out of range for DECIMAL(%d,%d)", value, dataLength, scale)
+		}
+		return converted, nil
+	case DateTime:
+		t, err := time.Parse(dateTimeLayout, value)
+		if err != nil {
+			if t, err = time.Parse(dateLayout, value); err != nil {
+				return "", fmt.Errorf("'%s' is not a valid DATETIME", value)
+			}
+		}
+		return t.Format(dateTimeLayout), nil
+	case Date:
+		// 日期时间只保留日期部分
+		t, err := time.Parse(dateLayout, value)
+		if err != nil {
+			if t, err = time.Parse(dateTimeLayout, value); err != nil {
+				return "", fmt.Errorf("'%s' is not a valid DATE", value)
+			}
+		}
+		return t.Format(dateLayout), nil
+	case Time:
+		// 可以省略秒，日期时间只保留时间部分
+		t, err := time.Parse(timeLayout, value)
+		for _, layout := range []string{"15:04", dateTimeLayout} {
+			if err != nil {
+				t, err = time.Parse(layout, value)
+			}
+		}
+		if err != nil {
+			return "", fmt.Errorf("'%s' is not a valid TIME", value)
+		}
+		return t.Format(timeLayout), nil
+	case Boolean:
+		return toBoolean(value)
+	case Varchar:
+		if dataLength > 0 && utf8.RuneCountInString(value) > dataLength {
+			return "", fmt.Errorf("'%s' is longer than VARCHAR(%d)", value, dataLength)
+		}
+	case Char:
+		// 末尾的空格不计入长度，不足的部分用空格补齐
+		value = strings.TrimRight(value, " ")
+		length := utf8.RuneCountInString(value)
+		if dataLength > 0 && length > dataLength {
+			return "", fmt.Errorf("'%s' is longer than CHAR(%d)", value, dataLength)
+		}
+		if dataLength > length {
+			value += strings.Repeat(" ", dataLength-length)
+		}
+	case Blob:
+		// \x开头的字符串是二进制数据的十六进制写法，其他字符串按照它的字节存储
+		if strings.HasPrefix(value, `\x`) {
+			bytes, err := hex.DecodeString(value[2:])
+			if err != nil {
+				return "", fmt.Errorf("'%s' is not a valid BLOB", value)
+			}
+			return `\x` + hex.EncodeToString(bytes), nil
+		}
+		return `\x` + hex.EncodeToString([]byte(value)), nil
+	}
+	return value, nil
+}
+
+// 检查要写入列中的值是否符合列的数据类型，并转换为规范形式，用于INSERT和UPDATE
+func convertFieldValue(field FieldJson, value string, operation string) (converted string, err error) {
+	converted, err = convertValue(value, field.DataType, field.DataLength, field.Scale)
+	if err != nil {
+		return "", fmt.Errorf("at %s: invalid value for field %s: %v", operation, field.Name, err)
+	}
+	return converted, nil
 }
@@ -0,0 +1,348 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 数据库中的一个对象：表、视图或者物化视图
+type dropObject struct {
+	Type Type   // 对象的类型：DropTable、DropView、DropMaterializedView
+	Name string // 对象的名称
+}
+
+// 依赖要删除的对象的对象，RESTRICT时报错，CASCADE时一起删除
+type dropDependents struct {
+	Objects     []dropObject        // 依赖的视图和物化视图，会被一起删除
+	Indexes     []string            // 建立在要删除的表上的索引文件
+	IndexNames  []string            // 索引文件对应的索引名，与Indexes一一对应
+	ForeignKeys map[string][]string // 引用了要删除的表的外键：表名到列名
+}
+
+// 删除表、视图和物化视图的处理器，返回删除的对象的个数
+func handleDrop(sql Sql) (rows int, err error) {
+	operation := strings.ToUpper(TypeString[sql.Type])
+	if len(sql.Tables) == 0 {
+		return 0, fmt.Errorf("at %s: expected name to DROP", operation)
+	}
+	// 先检查要删除的对象是否存在、类型是否正确
+	var objects []dropObject
+	for index, name := range sql.Tables {
+		// 同一个对象写了多次时只删除一次
+		if containsString(sql.Tables[:index], name) {
+			continue
+		}
+		objectType, err := getObjectType(name)
+		if err != nil {
+			return 0, err
+		}
+		if objectType == Unknown {
+			if sql.IfExists {
+				continue
+			}
+			return 0, fmt.Errorf("at %s: unknown %s name %s", operation, objectTypeString(sql.Type), name)
+		}
+		if objectType != sql.Type {
+			return 0, fmt.Errorf("at %s: %s is a %s", operation, name, objectTypeString(objectType))
+		}
+		objects = append(objects, dropObject{Type: objectType, Name: name})
+	}
+	if len(objects) == 0 {
+		return 0, nil
+	}
+
+	// 找出依赖要删除的对象的视图、索引和外键，CASCADE时依赖被删除的视图的对象也要删除
+	dependents, err := findDependents(objects, sql.Cascade)
+	if err != nil {
+		return 0, err
+	}
+	if !sql.Cascade {
+		// RESTRICT：有依赖时拒绝删除
+		if len(dependents.Objects) > 0 {
+			dependent := dependents.Objects[0]
+			return 0, fmt.Errorf("at %s: %s %s depends on it, use CASCADE to drop it too",
+				operation, objectTypeString(dependent.Type), dependent.Name)
+		}
+		if len(dependents.Indexes) > 0 {
+			return 0, fmt.Errorf("at %s: index %s depends on it, use CASCADE to drop it too",
+				operation, dependents.IndexNames[0])
+		}
+		for tableName, fieldNames := range dependents.ForeignKeys {
+			return 0, fmt.Errorf("at %s: foreign key %s.%s depends on it, use CASCADE to drop it too",
+				operation, tableName, fieldNames[0])
+		}
+	}
+	objects = append(objects, dependents.Objects...)
+
+	// 删除引用了被删除的表的外键约束，被引用的表中的数据保留
+	for tableName, fieldNames := range dependents.ForeignKeys {
+		table, err := readTableJson(tableName)
+		if err != nil {
+			return 0, err
+		}
+		for index, field := range table.Fields {
+			if containsString(fieldNames, field.Name) {
+				table.Fields[index].ForeignKey = false
+				table.Fields[index].ForeignKeyTable = ""
+				table.Fields[index].ForeignKeyColumn = ""
+				table.Fields[index].OnDelete = NoAction
+				table.Fields[index].OnUpdate = NoAction
+			}
+		}
+		var constraints []ConstraintJson
+		for _, constraint := range table.Constraints {
+			if constraint.ConstraintType != ForeignKey || !containsString(fieldNames, constraint.Fields[0]) {
+				constraints = append(constraints, constraint)
+			}
+		}
+		table.Constraints = constraints
+		if err = writeTableJson(table); err != nil {
+			panic(err)
+		}
+	}
+	// 删除索引文件
+	for _, fileName := range dependents.Indexes {
+		if err = os.Remove("./file/" + fileName); err != nil {
+			panic(err)
+		}
+	}
+	// 删除表和视图的文件，视图保存在txt文件中，表和物化视图保存在json文件中
+	var names []string
+	for _, object := range objects {
+		fileName := "./file/" + object.Name + ".json"
+		if object.Type == DropView {
+			fileName = "./file/" + object.Name + ".txt"
+		}
+		if err = os.Remove(fileName); err != nil {
+			panic(err)
+		}
+		names = append(names, object.Name)
+	}
+	// 收回用户在被删除的表和视图上的权限
+	if err = revokeDroppedPrivileges(names); err != nil {
+		panic(err)
+	}
+	return len(objects) + len(dependents.Indexes), nil
+}
+
+// 判断名称对应的对象的类型，不存在时返回Unknown
+func getObjectType(name string) (objectType Type, err error) {
+	fileName, err := getObjectFileByName(name + ".txt")
+	if err != nil {
+		return Unknown, err
+	}
+	if fileName != "" {
+		return DropView, nil
+	}
+	fileName, err = getObjectFileByName(name + ".json")
+	if err != nil || fileName == "" {
+		return Unknown, err
+	}
+	table, err := readTableJson(name)
+	if err != nil {
+		return Unknown, err
+	}
+	if table.Definition != "" {
+		return DropMaterializedView, nil
+	}
+	return DropTable, nil
+}
+
+// 对象的类型在错误信息中的名称
+func objectTypeString(objectType Type) string {
+	switch objectType {
+	case DropView:
+		return "view"
+	case DropMaterializedView:
+		return "materialized view"
+	default:
+		return "table"
+	}
+}
+
+// 找出依赖要删除的对象的视图、物化视图、索引和外键
+// cascade为true时被删除的视图也是要删除的对象，依赖它们的对象也会找出来
+func findDependents(objects []dropObject, cascade bool) (dependents *dropDependents, err error) {
+	dependents = &dropDependents{ForeignKeys: map[string][]string{}}
+	tables, _, views, materializedViews, err := getFilesForHelpDataBase()
+	if err != nil {
+		return nil, err
+	}
+	// 所有视图和物化视图引用的表和视图
+	references := map[string][]string{}
+	var candidates []dropObject
+	for _, fileName := range views {
+		name := strings.TrimSuffix(fileName, ".txt")
+		view, err := readView(name)
+		if err != nil {
+			return nil, err
+		}
+		query, err := parseSelect(view.ViewSelect)
+		if err != nil {
+			return nil, fmt.Errorf("at view %s: %v", name, err)
+		}
+		references[name] = referencedTables(query)
+		candidates = append(candidates, dropObject{Type: DropView, Name: name})
+	}
+	for _, fileName := range materializedViews {
+		name := strings.TrimSuffix(fileName, ".json")
+		table, err := readTableJson(name)
+		if err != nil {
+			return nil, err
+		}
+		view, err := parse(table.Definition)
+		if err != nil {
+			return nil, fmt.Errorf("at materialized view %s: %v", name, err)
+		}
+		query, err := parseSelect(view.ViewSelect)
+		if err != nil {
+			return nil, fmt.Errorf("at materialized view %s: %v", name, err)
+		}
+		references[name] = referencedTables(query)
+		candidates = append(candidates, dropObject{Type: DropMaterializedView, Name: name})
+	}
+
+	dropped := map[string]bool{}
+	for _, object := range objects {
+		dropped[object.Name] = true
+	}
+	// 依次处理每一个要删除的对象，CASCADE时新找到的视图也加入队列
+	queue := append([]dropObject{}, objects...)
+	for len(queue) > 0 {
+		object := queue[0]
+		queue = queue[1:]
+		for _, candidate := range candidates {
+			if dropped[candidate.Name] || !containsString(references[candidate.Name], object.Name) {
+				continue
+			}
+			dependents.Objects = append(dependents.Objects, candidate)
+			if cascade {
+				dropped[candidate.Name] = true
+				queue = append(queue, candidate)
+			}
+		}
+		// 视图上没有索引
+		if object.Type == DropView {
+			continue
+		}
+		indexes, err := getIndexFiles("", object.Name)
+		if err != nil {
+			return nil, err
+		}
+		dependents.Indexes = append(dependents.Indexes, indexes...)
+		for _, fileName := range indexes {
+			dependents.IndexNames = append(dependents.IndexNames, indexNameOfFile(fileName, object.Name))
+		}
+	}
+	// 没有被删除的表中引用了被删除的表的外键
+	for _, fileName := range tables {
+		name := strings.TrimSuffix(fileName, ".json")
+		if dropped[name] {
+			continue
+		}
+		table, err := readTableJson(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range table.Fields {
+			if field.ForeignKey && dropped[field.ForeignKeyTable] {
+				dependents.ForeignKeys[name] = append(dependents.ForeignKeys[name], field.Name)
+			}
+		}
+	}
+	return dependents, nil
+}
+
+// 收回所有用户在被删除的表和视图上的权限
+func revokeDroppedPrivileges(names []string) (err error) {
+	users, err := readUsersJson()
+	if err != nil || users == nil {
+		return err
+	}
+	remain := func(privileges []TableAndFields) []TableAndFields {
+		result := []TableAndFields{}
+		for _, privilege := range privileges {
+			if !containsString(names, privilege.TableName) {
+				result = append(result, privilege)
+			}
+		}
+		return result
+	}
+	for index, user := range users.Users {
+		users.Users[index].SelectPrivileges = remain(user.SelectPrivileges)
+		users.Users[index].InsertPrivileges = remain(user.InsertPrivileges)
+		users.Users[index].UpdatePrivileges = remain(user.UpdatePrivileges)
+		users.Users[index].DeletePrivileges = remain(user.DeletePrivileges)
+	}
+	return writeUsersJson(users)
+}
+
+// 删除索引的处理器，返回删除的索引文件的个数
+func handleDropIndex(sql Sql) (rows int, err error) {
+	if sql.IndexName == "" {
+		return 0, fmt.Errorf("at DROP INDEX: expected name to DROP")
+	}
+	tableName := ""
+	if len(sql.Tables) > 0 {
+		tableName = sql.Tables[0]
+	}
+	files, err := getIndexFiles(sql.IndexName, tableName)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		if sql.IfExists {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("at DROP INDEX: unknown index name %s", sql.IndexName)
+	}
+	for _, fileName := range files {
+		if err = os.Remove("./file/" + fileName); err != nil {
+			panic(err)
+		}
+	}
+	return len(files), nil
+}
+
+// 删除用户的处理器，返回删除的用户的个数
+func handleDropUser(sql Sql) (rows int, err error) {
+	if len(sql.Users) == 0 {
+		return 0, fmt.Errorf("at DROP USER: expected name to DROP")
+	}
+	users, err := readUsersJson()
+	if err != nil {
+		return 0, err
+	}
+	if users == nil {
+		users = &UsersJson{Users: []UserJson{}}
+	}
+	// 先检查所有的用户是否存在
+	for _, username := range sql.Users {
+		found := false
+		for _, user := range users.Users {
+			if user.UserName == username {
+				found = true
+			}
+		}
+		if !found && !sql.IfExists {
+			return 0, fmt.Errorf("at DROP USER: unknown user name %s", username)
+		}
+	}
+	remain := []UserJson{}
+	for _, user := range users.Users {
+		if containsString(sql.Users, user.UserName) {
+			rows++
+			continue
+		}
+		remain = append(remain, user)
+	}
+	if rows == 0 {
+		return 0, nil
+	}
+	users.Users = remain
+	if err = writeUsersJson(users); err != nil {
+		panic(err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,775 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// 表达式，用于查询的列、UPDATE的SET子句和Where子句中比较的两侧
+// 例如"Sage + 1"解析为 BinaryExpression(+, FieldExpression(Sage), LiteralExpression(1))
+type Expression struct {
+	Type      ExpressionType // 表达式的类型
+	Value     string         // 字面值，或者列名
+	Quoted    bool           // 字面值是否带有单引号，带单引号的是字符串
+	Operator  string         // 运算符：+ - * / % ||，一元负号为-
+	Left      *Expression    // 二元运算的左操作数，一元负号和类型转换的操作数
+	Right     *Expression    // 二元运算的右操作数
+	Aggregate Aggregate      // 聚集函数
+	Function  string         // 标量函数的函数名，大写
+	Arguments []*Expression  // 标量函数的参数
+	Subquery  *Sql           // 标量子查询
+	// 类型转换的目标类型，DataLength是字符串的长度或者DECIMAL的精度，Scale是DECIMAL的小数位数
+	DataType   DataType
+	DataLength int
+	Scale      int
+}
+
+// 表达式的类型
+type ExpressionType int
+
+const (
+	UnknownExpression   ExpressionType = iota // 未知的表达式
+	LiteralExpression                         // 字面值：'CS'、20、3.5
+	FieldExpression                           // 列名：Sage、Student.Sno，查询的列中还可以是*、Student.*
+	BinaryExpression                          // 二元运算：Sage + 1、Sname || Sdept
+	NegativeExpression                        // 一元负号：-Sage
+	AggregateExpression                       // 聚集函数：COUNT(*)、SUM(Grade * 2)
+	FunctionExpression                        // 标量函数：UPPER(Sname)、ROUND(Grade / 3, 1)
+	SubqueryExpression                        // 标量子查询：(SELECT AVG(Grade) FROM SC)，结果只能有一行一列
+	CastExpression                            // 类型转换：CAST(Sage AS VARCHAR(10))
+)
+
+// 表达式中的二元运算符，按优先级从低到高分组，同一组的运算符优先级相同
+var binaryOperators = [][]string{
+	{"||"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// 把条件的操作数转换为表达式：解析出的条件直接带有表达式，Check约束的条件只有字符串形式的操作数
+func conditionExpression(expression *Expression, operand string, isField bool) *Expression {
+	if expression != nil {
+		return expression
+	}
+	if isField {
+		return &Expression{Type: FieldExpression, Value: operand}
+	}
+	return &Expression{Type: LiteralExpression, Value: operand, Quoted: true}
+}
+
+// 表达式中引用的所有列名；includeAggregate为false时不包括聚集函数内部引用的列
+func (expression *Expression) fields(includeAggregate bool) (fields []string) {
+	if expression == nil {
+		return nil
+	}
+	switch expression.Type {
+	case FieldExpression:
+		return []string{expression.Value}
+	case AggregateExpression:
+		if includeAggregate {
+			return expression.Aggregate.Expression.fields(true)
+		}
+		return nil
+	}
+	fields = append(expression.Left.fields(includeAggregate), expression.Right.fields(includeAggregate)...)
+	for _, argument := range expression.Arguments {
+		fields = append(fields, argument.fields(includeAggregate)...)
+	}
+	return fields
+}
+
+// 把表达式中引用tableName表fieldName列的列名改为newName，列名可以带上表名
+func (expression *Expression) renameField(tableName string, fieldName string, newName string) {
+	if expression == nil {
+		return
+	}
+	if expression.Type == FieldExpression {
+		expression.Value = renameFieldReference(expression.Value, tableName, fieldName, newName)
+	}
+	expression.Aggregate.Expression.renameField(tableName, fieldName, newName)
+	expression.Left.renameField(tableName, fieldName, newName)
+	expression.Right.renameField(tableName, fieldName, newName)
+	for _, argument := range expression.Arguments {
+		argument.renameField(tableName, fieldName, newName)
+	}
+}
+
+// 列名是fieldName或者tableName.fieldName时改为新的列名，保留表名前缀
+func renameFieldReference(reference string, tableName string, fieldName string, newName string) string {
+	switch reference {
+	case fieldName:
+		return newName
+	case tableName + "." + fieldName:
+		return tableName + "." + newName
+	}
+	return reference
+}
+
+// 表达式中用到的所有聚集函数
+func (expression *Expression) aggregates() (aggregates []Aggregate) {
+	if expression == nil {
+		return nil
+	}
+	if expression.Type == AggregateExpression {
+		return []Aggregate{expression.Aggregate}
+	}
+	aggregates = append(expression.Left.aggregates(), expression.Right.aggregates()...)
+	for _, argument := range expression.Arguments {
+		aggregates = append(aggregates, argument.aggregates()...)
+	}
+	return aggregates
+}
+
+// 表达式中是否有子查询
+func (expression *Expression) hasSubquery() bool {
+	if expression == nil {
+		return false
+	}
+	if expression.Type == SubqueryExpression {
+		return true
+	}
+	for _, argument := range expression.Arguments {
+		if argument.hasSubquery() {
+			return true
+		}
+	}
+	return expression.Left.hasSubquery() || expression.Right.hasSubquery() || expression.Aggregate.Expression.hasSubquery()
+}
+
+// 把表达式中引用的列替换为columns中对应的表达式，返回替换后的新表达式，用于展开视图的列
+// 子查询中引用的列不替换
+func (expression *Expression) substituteFields(columns map[string]*Expression) (substituted *Expression, err error) {
+	if expression == nil {
+		return nil, nil
+	}
+	if expression.Type == FieldExpression {
+		column, ok := columns[expression.Value]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %s", expression.Value)
+		}
+		return column, nil
+	}
+	copied := *expression
+	if copied.Left, err = expression.Left.substituteFields(columns); err != nil {
+		return nil, err
+	}
+	if copied.Right, err = expression.Right.substituteFields(columns); err != nil {
+		return nil, err
+	}
+	copied.Arguments = nil
+	for _, argument := range expression.Arguments {
+		argument, err = argument.substituteFields(columns)
+		if err != nil {
+			return nil, err
+		}
+		copied.Arguments = append(copied.Arguments, argument)
+	}
+	return &copied, nil
+}
+
+// 计算表达式在一行数据上的值，返回值和数据类型
+// 带单引号的字面值没有确定的类型，返回UnknownDataType，与其他值比较时按照另一个值的类型处理
+// 空值参与运算的结果都是空值
+func (expression *Expression) evaluate(getValue valueGetter) (value string, dataType DataType, err error) {
+	switch expression.Type {
+	case LiteralExpression:
+		if expression.Quoted {
+			return expression.Value, UnknownDataType, nil
+		}
+		return expression.Value, numberDataType(expression.Value), nil
+	case FieldExpression:
+		return getValue(expression.Value)
+	case AggregateExpression:
+		// 聚集函数在分组时已经计算好，作为中间结果中的一列
+		return getValue(expression.Aggregate.Name())
+	case NegativeExpression:
+		value, dataType, err := expression.Left.evaluate(getValue)
+		if err != nil || value == NullValue {
+			return value, dataType, err
+		}
+		return arithmetic("-", "0", SmallInt, value, dataType)
+	case BinaryExpression:
+		left, leftType, err := expression.Left.evaluate(getValue)
+		if err != nil {
+			return "", UnknownDataType, err
+		}
+		right, rightType, err := expression.Right.evaluate(getValue)
+		if err != nil {
+			return "", UnknownDataType, err
+		}
+		if expression.Operator == "||" {
+			if left == NullValue || right == NullValue {
+				return NullValue, Varchar, nil
+			}
+			return left + right, Varchar, nil
+		}
+		if left == NullValue || right == NullValue {
+			return NullValue, arithmeticDataType(expression.Operator, leftType, rightType), nil
+		}
+		return arithmetic(expression.Operator, left, leftType, right, rightType)
+	case FunctionExpression:
+		var arguments []string
+		var argumentTypes []DataType
+		for _, argument := range expression.Arguments {
+			value, dataType, err := argument.evaluate(getValue)
+			if err != nil {
+				return "", UnknownDataType, err
+			}
+			arguments = append(arguments, value)
+			argumentTypes = append(argumentTypes, dataType)
+		}
+		return callFunction(expression.Function, arguments, argumentTypes)
+	case SubqueryExpression:
+		// 当前行作为外层查询的行执行子查询，没有结果时为空值
+		values, dataType, err := subqueryValues(*expression.Subquery, getValue)
+		if err != nil {
+			return "", UnknownDataType, err
+		}
+		if len(values) > 1 {
+			return "", UnknownDataType, fmt.Errorf("at subquery: scalar subquery returned more than one row")
+		}
+		if len(values) == 0 {
+			return NullValue, dataType, nil
+		}
+		return values[0], dataType, nil
+	case CastExpression:
+		value, _, err := expression.Left.evaluate(getValue)
+		if err != nil {
+			return "", UnknownDataType, err
+		}
+		value, err = convertValue(value, expression.DataType, expression.DataLength, expression.Scale)
+		if err != nil {
+			return "", UnknownDataType, fmt.Errorf("at CAST: %v", err)
+		}
+		return value, expression.DataType, nil
+	default:
+		return "", UnknownDataType, fmt.Errorf("unknown expression")
+	}
+}
+
+// 推断表达式结果的数据类型，同时检查运算符和函数的参数类型是否正确
+// 带单引号的字面值没有确定的类型，返回UnknownDataType；set为nil时是在解析阶段检查，列的类型还不知道，也返回UnknownDataType
+func (expression *Expression) dataType(set *resultSet) (dataType DataType, err error) {
+	switch expression.Type {
+	case LiteralExpression:
+		if expression.Quoted {
+			return UnknownDataType, nil
+		}
+		return numberDataType(expression.Value), nil
+	case FieldExpression:
+		if set == nil {
+			return UnknownDataType, nil
+		}
+		// 相关子查询中外层查询的列
+		if _, count := set.findField(expression.Value); count == 0 && set.outer != nil {
+			_, dataType, err := set.outer(expression.Value)
+			return dataType, err
+		}
+		index, err := set.fieldIndex(expression.Value)
+		if err != nil {
+			return UnknownDataType, err
+		}
+		return set.fields[index].field.DataType, nil
+	case AggregateExpression:
+		// 分组之后聚集函数已经是中间结果中的一列
+		if set != nil {
+			if index, err := set.fieldIndex(expression.Aggregate.Name()); err == nil {
+				return set.fields[index].field.DataType, nil
+			}
+		}
+		return aggregateDataType(expression.Aggregate, set)
+	case NegativeExpression:
+		dataType, err := expression.Left.dataType(set)
+		if err != nil {
+			return UnknownDataType, err
+		}
+		if !numberArgument.accepts(dataType) {
+			return UnknownDataType, fmt.Errorf("at -: cannot apply - to %s", DataTypeString[dataType])
+		}
+		return dataType, nil
+	case BinaryExpression:
+		leftType, err := expression.Left.dataType(set)
+		if err != nil {
+			return UnknownDataType, err
+		}
+		rightType, err := expression.Right.dataType(set)
+		if err != nil {
+			return UnknownDataType, err
+		}
+		if expression.Operator == "||" {
+			return Varchar, nil
+		}
+		for _, dataType := range []DataType{leftType, rightType} {
+			if !numberArgument.accepts(dataType) {
+				return UnknownDataType, fmt.Errorf("at %s: cannot apply %s to %s", expression.Operator, expression.Operator, DataTypeString[dataType])
+			}
+		}
+		return arithmeticDataType(expression.Operator, leftType, rightType), nil
+	case FunctionExpression:
+		var argumentTypes []DataType
+		for _, argument := range expression.Arguments {
+			dataType, err := argument.dataType(set)
+			if err != nil {
+				return UnknownDataType, err
+			}
+			argumentTypes = append(argumentTypes, dataType)
+		}
+		if dataType, err = functionDataType(expression.Function, argumentTypes); err != nil {
+			return UnknownDataType, err
+		}
+		// 带单引号的字面值参数的类型在解析时就能确定
+		if err = checkLiteralArguments(expression.Function, expression.Arguments); err != nil {
+			return UnknownDataType, err
+		}
+		return dataType, nil
+	case SubqueryExpression:
+		if set == nil {
+			return UnknownDataType, nil
+		}
+		// 用空值代替外层查询的当前行执行一次子查询，得到结果的类型
+		_, dataType, err := subqueryValues(*expression.Subquery, set.schemaGetter())
+		return dataType, err
+	case CastExpression:
+		if _, err := expression.Left.dataType(set); err != nil {
+			return UnknownDataType, err
+		}
+		return expression.DataType, nil
+	default:
+		return UnknownDataType, fmt.Errorf("unknown expression")
+	}
+}
+
+// 不带单引号的字面值的数据类型：SMALLINT范围内的整数为SMALLINT，更大的整数为BIGINT，其他数字为DOUBLE
+func numberDataType(value string) DataType {
+	if _, err := strconv.ParseInt(value, 10, 32); err == nil {
+		return SmallInt
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return BigInt
+	}
+	return Double
+}
+
+// 算术运算结果的数据类型：有DECIMAL参与时按DECIMAL精确计算，两个整数运算的结果还是整数，否则为DOUBLE
+func arithmeticDataType(operator string, leftType DataType, rightType DataType) DataType {
+	switch {
+	case leftType == Decimal || rightType == Decimal:
+		return Decimal
+	case isIntegerType(leftType) && isIntegerType(rightType):
+		if leftType == BigInt || rightType == BigInt {
+			return BigInt
+		}
+		return SmallInt
+	}
+	return Double
+}
+
+// 确定参与运算的值的数值类型，没有确定类型的值根据内容判断是整数还是浮点数
+func numberType(value string, dataType DataType) (resultType DataType, err error) {
+	if dataType == UnknownDataType {
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return UnknownDataType, fmt.Errorf("%s is not a number", value)
+		}
+		return numberDataType(value), nil
+	}
+	if !isNumberType(dataType) {
+		return UnknownDataType, fmt.Errorf("%s value %s is not a number", DataTypeString[dataType], value)
+	}
+	return dataType, nil
+}
+
+// 把值转换为数字，没有确定类型的值根据内容判断是整数还是浮点数
+func toNumber(value string, dataType DataType) (number float64, resultType DataType, err error) {
+	if dataType, err = numberType(value, dataType); err != nil {
+		return 0, UnknownDataType, err
+	}
+	number, err = strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, UnknownDataType, fmt.Errorf("%s is not a number", value)
+	}
+	return number, dataType, nil
+}
+
+// 计算两个数字的算术运算，两个整数的除法和取余按照整数运算
+// 整数和DECIMAL都按十进制精确计算，只有DOUBLE使用浮点数计算
+func arithmetic(operator string, left string, leftType DataType, right string, rightType DataType) (value string, dataType DataType, err error) {
+	if leftType, err = numberType(left, leftType); err != nil {
+		return "", UnknownDataType, fmt.Errorf("at %s: %v", operator, err)
+	}
+	if rightType, err = numberType(right, rightType); err != nil {
+		return "", UnknownDataType, fmt.Errorf("at %s: %v", operator, err)
+	}
+	dataType = arithmeticDataType(operator, leftType, rightType)
+	switch dataType {
+	case Decimal:
+		value, err = decimalArithmetic(operator, left, right)
+	case SmallInt, BigInt:
+		value, err = integerArithmetic(operator, left, right, dataType)
+	default:
+		value, err = floatArithmetic(operator, left, right)
+	}
+	if err != nil {
+		return "", UnknownDataType, err
+	}
+	return value, dataType, nil
+}
+
+// 浮点数的算术运算
+func floatArithmetic(operator string, left string, right string) (value string, err error) {
+	x, err := strconv.ParseFloat(left, 64)
+	if err != nil {
+		return "", fmt.Errorf("at %s: %s is not a number", operator, left)
+	}
+	y, err := strconv.ParseFloat(right, 64)
+	if err != nil {
+		return "", fmt.Errorf("at %s: %s is not a number", operator, right)
+	}
+	if (operator == "/" || operator == "%") && y == 0 {
+		return "", fmt.Errorf("at %s: division by zero", operator)
+	}
+	var result float64
+	switch operator {
+	case "+":
+		result = x + y
+	case "-":
+		result = x - y
+	case "*":
+		result = x * y
+	case "/":
+		result = x / y
+	case "%":
+		result = math.Mod(x, y)
+	default:
+		return "", fmt.Errorf("unknown operator %s", operator)
+	}
+	return formatNumber(result, Double), nil
+}
+
+// 整数的算术运算，除法的结果向零取整，BIGINT的结果超出范围时报错
+func integerArithmetic(operator string, left string, right string, dataType DataType) (value string, err error) {
+	x, okX := new(big.Int).SetString(left, 10)
+	y, okY := new(big.Int).SetString(right, 10)
+	if !okX || !okY {
+		return "", fmt.Errorf("at %s: cannot compute %s %s %s as %s", operator, left, operator, right, DataTypeString[dataType])
+	}
+	if (operator == "/" || operator == "%") && y.Sign() == 0 {
+		return "", fmt.Errorf("at %s: division by zero", operator)
+	}
+	result := new(big.Int)
+	switch operator {
+	case "+":
+		result.Add(x, y)
+	case "-":
+		result.Sub(x, y)
+	case "*":
+		result.Mul(x, y)
+	case "/":
+		result.Quo(x, y)
+	case "%":
+		result.Rem(x, y)
+	default:
+		return "", fmt.Errorf("unknown operator %s", operator)
+	}
+	if dataType == BigInt && !result.IsInt64() {
+		return "", fmt.Errorf("at %s: BIGINT out of range", operator)
+	}
+	return result.String(), nil
+}
+
+// DECIMAL的算术运算，按十进制精确计算
+// 结果的小数位数：加减为两边小数位数的最大值，乘法为两边小数位数之和，除法为被除数的小数位数加4
+func decimalArithmetic(operator string, left string, right string) (value string, err error) {
+	x, err := parseDecimal(left)
+	if err != nil {
+		return "", fmt.Errorf("at %s: %v", operator, err)
+	}
+	y, err := parseDecimal(right)
+	if err != nil {
+		return "", fmt.Errorf("at %s: %v", operator, err)
+	}
+	if (operator == "/" || operator == "%") && y.Sign() == 0 {
+		return "", fmt.Errorf("at %s: division by zero", operator)
+	}
+	scale := max(decimalScale(left), decimalScale(right))
+	result := new(big.Rat)
+	switch operator {
+	case "+":
+		result.Add(x, y)
+	case "-":
+		result.Sub(x, y)
+	case "*":
+		result.Mul(x, y)
+		scale = decimalScale(left) + decimalScale(right)
+	case "/":
+		result.Quo(x, y)
+		scale = decimalScale(left) + 4
+	case "%":
+		// x % y = x - y * trunc(x / y)
+		quotient := new(big.Rat).Quo(x, y)
+		truncated := new(big.Rat).SetInt(new(big.Int).Quo(quotient.Num(), quotient.Denom()))
+		result.Sub(x, truncated.Mul(truncated, y))
+	default:
+		return "", fmt.Errorf("unknown operator %s", operator)
+	}
+	return result.FloatString(scale), nil
+}
+
+// 把数字转换为字符串存储
+func formatNumber(number float64, dataType DataType) string {
+	if isIntegerType(dataType) {
+		return strconv.FormatInt(int64(number), 10)
+	}
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+// 比较时使用的数据类型：没有确定类型的一侧使用另一侧的类型
+// 不同的数值类型比较时，有DECIMAL时按DECIMAL比较，有DOUBLE时按浮点数比较，否则按BIGINT比较
+// DATE与DATETIME比较时按DATETIME比较，CHAR与其他字符串比较时忽略末尾的空格
+func comparisonDataType(leftType DataType, rightType DataType) DataType {
+	if leftType == UnknownDataType {
+		return rightType
+	}
+	if rightType == UnknownDataType || leftType == rightType {
+		return leftType
+	}
+	switch {
+	case isNumberType(leftType) && isNumberType(rightType):
+		if leftType == Decimal || rightType == Decimal {
+			return Decimal
+		}
+		if leftType == Double || rightType == Double {
+			return Double
+		}
+		return BigInt
+	case (leftType == Date && rightType == DateTime) || (leftType == DateTime && rightType == Date):
+		return DateTime
+	case isStringType(leftType) && isStringType(rightType):
+		if leftType == Char || rightType == Char {
+			return Char
+		}
+		return Varchar
+	}
+	return leftType
+}
+
+// 解析表达式，返回表达式和它在SQL语句中的原文
+// 运算符的优先级从低到高依次为：||；+ -；* / %；一元负号
+func (p *parser) parseExpression() (expression *Expression, text string, err error) {
+	start := p.position
+	expression, err = p.parseBinaryExpression(0)
+	if err != nil {
+		return nil, "", err
+	}
+	return expression, strings.TrimSpace(p.sql[start:p.position]), nil
+}
+
+// 解析优先级不低于binaryOperators[level]的二元运算
+func (p *parser) parseBinaryExpression(level int) (expression *Expression, err error) {
+	if level >= len(binaryOperators) {
+		return p.parseUnaryExpression()
+	}
+	expression, err = p.parseBinaryExpression(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator := p.peek()
+		if !containsString(binaryOperators[level], operator) {
+			return expression, nil
+		}
+		p.pop()
+		right, err := p.parseBinaryExpression(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		expression = &Expression{Type: BinaryExpression, Operator: operator, Left: expression, Right: right}
+	}
+}
+
+// 解析一元负号
+func (p *parser) parseUnaryExpression() (expression *Expression, err error) {
+	// 带单引号的字符串，即使内容是'-'也不是负号
+	if p.position < len(p.sql) && p.sql[p.position] == '\'' {
+		return p.parsePrimaryExpression()
+	}
+	switch p.peek() {
+	case "-":
+		p.pop()
+		operand, err := p.parseUnaryExpression()
+		if err != nil {
+			return nil, err
+		}
+		return &Expression{Type: NegativeExpression, Operator: "-", Left: operand}, nil
+	case "+":
+		p.pop()
+		return p.parseUnaryExpression()
+	}
+	return p.parsePrimaryExpression()
+}
+
+// 解析括号、字面值、列名和聚集函数
+func (p *parser) parsePrimaryExpression() (expression *Expression, err error) {
+	if p.position >= len(p.sql) {
+		return nil, fmt.Errorf("expected expression")
+	}
+	// 带单引号的字符串
+	if p.sql[p.position] == '\'' {
+		return &Expression{Type: LiteralExpression, Value: p.pop(), Quoted: true}, nil
+	}
+	if p.peekSubquery() {
+		subquery, err := p.popSubquery()
+		if err != nil {
+			return nil, err
+		}
+		return &Expression{Type: SubqueryExpression, Subquery: subquery}, nil
+	}
+	token := p.peek()
+	if token == "(" {
+		p.pop()
+		expression, err = p.parseBinaryExpression(0)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("expected closing parens ')'")
+		}
+		p.pop()
+		return expression, nil
+	}
+	// 数字
+	if IsNum(token) {
+		p.pop()
+		return &Expression{Type: LiteralExpression, Value: token}, nil
+	}
+	// 没有单引号的NULL是空值
+	if strings.ToUpper(token) == "NULL" {
+		p.pop()
+		return &Expression{Type: LiteralExpression, Value: NullValue, Quoted: true}, nil
+	}
+	// 布尔值的字面值，没有确定的类型，与BOOLEAN的值比较时按BOOLEAN比较
+	if strings.ToUpper(token) == "TRUE" || strings.ToUpper(token) == "FALSE" {
+		p.pop()
+		return &Expression{Type: LiteralExpression, Value: strings.ToUpper(token), Quoted: true}, nil
+	}
+	if !isIdentifier(token) {
+		return nil, fmt.Errorf("unexpected token %s in expression", token)
+	}
+	p.pop()
+	// 函数名后面紧跟着左括号
+	if p.peek() == "(" {
+		if strings.ToUpper(token) == "CAST" {
+			return p.parseCast()
+		}
+		for index, functionName := range AggregateFunctionString {
+			if index > 0 && strings.ToUpper(token) == functionName {
+				return p.parseAggregate(AggregateFunction(index))
+			}
+		}
+		return p.parseFunction(strings.ToUpper(token))
+	}
+	return &Expression{Type: FieldExpression, Value: token}, nil
+}
+
+// 解析聚集函数的参数部分：([DISTINCT] 表达式) 或者 (*)
+func (p *parser) parseAggregate(function AggregateFunction) (expression *Expression, err error) {
+	functionName := AggregateFunctionString[function]
+	// 弹出左括号
+	p.pop()
+	aggregate := Aggregate{Function: function}
+	if p.peek() == "DISTINCT" {
+		aggregate.Distinct = true
+		p.pop()
+	}
+	if p.peek() == "*" {
+		// 只有COUNT可以作用在*上
+		if function != CountAggregate || aggregate.Distinct {
+			return nil, fmt.Errorf("at %s: unexpected *", functionName)
+		}
+		aggregate.Field = p.pop()
+	} else {
+		aggregate.Expression, aggregate.Field, err = p.parseExpression()
+		if err != nil {
+			return nil, fmt.Errorf("at %s: %v", functionName, err)
+		}
+		if len(aggregate.Expression.aggregates()) > 0 {
+			return nil, fmt.Errorf("at %s: aggregate functions cannot be nested", functionName)
+		}
+	}
+	if p.peek() != ")" {
+		return nil, fmt.Errorf("at %s: expected closing parens ')'", functionName)
+	}
+	p.pop()
+	return &Expression{Type: AggregateExpression, Aggregate: aggregate}, nil
+}
+
+// 解析类型转换的参数部分：(表达式 AS 类型)，类型的写法与建表时相同，例如VARCHAR(10)、DECIMAL(5,2)
+func (p *parser) parseCast() (expression *Expression, err error) {
+	// 弹出左括号
+	p.pop()
+	operand, _, err := p.parseExpression()
+	if err != nil {
+		return nil, fmt.Errorf("at CAST: %v", err)
+	}
+	if strings.ToUpper(p.peek()) != "AS" {
+		return nil, fmt.Errorf("at CAST: expected AS")
+	}
+	p.pop()
+	expression = &Expression{Type: CastExpression, Left: operand}
+	typeName := strings.ToUpper(p.pop())
+	for index, name := range DataTypeString {
+		if index > 0 && typeName == name {
+			expression.DataType = DataType(index)
+		}
+	}
+	switch expression.DataType {
+	case UnknownDataType:
+		return nil, fmt.Errorf("at CAST: unknown data type %s", typeName)
+	case Decimal:
+		// 没有给出精度时为DECIMAL(10,0)
+		expression.DataLength = defaultDecimalPrecision
+	case Char:
+		// 没有给出长度时为CHAR(1)
+		expression.DataLength = 1
+	}
+	// 类型的长度，DECIMAL还可以有小数位数
+	if p.peek() == "(" {
+		p.pop()
+		if expression.DataLength, err = strconv.Atoi(p.peek()); err != nil {
+			return nil, fmt.Errorf("at CAST: length %s is not an integer", p.peek())
+		}
+		p.pop()
+		if expression.DataType == Decimal && p.peek() == "," {
+			p.pop()
+			if expression.Scale, err = strconv.Atoi(p.peek()); err != nil {
+				return nil, fmt.Errorf("at CAST: scale %s is not an integer", p.peek())
+			}
+			p.pop()
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("at CAST: expected closing parens ')'")
+		}
+		p.pop()
+	}
+	if expression.DataType == Decimal && (expression.DataLength < 1 || expression.DataLength > maxDecimalPrecision ||
+		expression.Scale < 0 || expression.Scale > expression.DataLength) {
+		return nil, fmt.Errorf("at CAST: invalid DECIMAL(%d,%d)", expression.DataLength, expression.Scale)
+	}
+	if p.peek() != ")" {
+		return nil, fmt.Errorf("at CAST: expected closing parens ')'")
+	}
+	p.pop()
+	return expression, nil
+}
+
+// 判断字符串是否在数组中
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -52,6 +54,27 @@ func getFileByName(name string) (file string, err error) {
 	return "", err
 }
 
+// 判断文件是否为用户文件或者索引文件，这两种文件都不是表、视图或者物化视图
+func isSystemFile(fileName string) bool {
+	return fileName == "users.json" || strings.Contains(fileName, "idx")
+}
+
+// 查找表、视图或者物化视图的文件，用户文件和索引文件不是数据库对象，视为不存在
+func getObjectFileByName(name string) (file string, err error) {
+	if isSystemFile(name) {
+		return "", nil
+	}
+	return getFileByName(name)
+}
+
+// 表和物化视图的名称不能与用户文件或者索引文件的名称冲突，否则创建时会覆盖这些文件
+func checkObjectName(name string, operation string) error {
+	if isSystemFile(name + ".json") {
+		return fmt.Errorf("at %s: %s is a reserved name", operation, name)
+	}
+	return nil
+}
+
 // 查找包含某个名称的文件，用于查看已有索引
 func getFilesByNameLike(name string) (files []string, err error) {
 	dir, err := ioutil.ReadDir("./file")
@@ -67,10 +90,10 @@ func getFilesByNameLike(name string) (files []string, err error) {
 }
 
 // 将文件分类，用于help database命令
-func getFilesForHelpDataBase() (tables []string, indexes []string, views []string, err error) {
+func getFilesForHelpDataBase() (tables []string, indexes []string, views []string, materializedViews []string, err error) {
 	dir, err := ioutil.ReadDir("./file")
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 	for _, file := range dir {
 		// users.json是存储用户和权限的文件，不需要处理
@@ -85,11 +108,136 @@ func getFilesForHelpDataBase() (tables []string, indexes []string, views []strin
 		if strings.Contains(file.Name(), "idx") {
 			indexes = append(indexes, file.Name())
 		}
-		// 不含有idx的json文件是表
+		// 不含有idx的json文件是表，保存了视图定义的是物化视图
 		if strings.Contains(file.Name(), ".json") && !strings.Contains(file.Name(), "idx") {
-			tables = append(tables, file.Name())
+			table, err := readTableJson(strings.TrimSuffix(file.Name(), ".json"))
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			if table.Definition != "" {
+				materializedViews = append(materializedViews, file.Name())
+			} else {
+				tables = append(tables, file.Name())
+			}
 		}
 	}
 	// 没有错误，返回
-	return tables, indexes, views, nil
+	return tables, indexes, views, materializedViews, nil
+}
+
+// 读取表名对应的JSON文件，转换为表的存储结构
+func readTableJson(tableName string) (table *TableJson, err error) {
+	fileName, err := getObjectFileByName(tableName + ".json")
+	if err != nil {
+		return nil, err
+	}
+	// 不存在这个名称的表文件，说明该表不存在
+	if fileName == "" {
+		return nil, fmt.Errorf("unknown table name %s", tableName)
+	}
+	bytes, err := ioutil.ReadFile("./file/" + fileName)
+	if err != nil {
+		return nil, err
+	}
+	table = &TableJson{}
+	err = json.Unmarshal(bytes, table)
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// 把表的存储结构覆盖写入对应的JSON文件
+func writeTableJson(table *TableJson) (err error) {
+	jsonTable, err := json.Marshal(table)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile("./file/"+table.Name+".json", jsonTable, os.ModeAppend)
+}
+
+// 读取视图名对应的txt文件，解析出视图的定义，不存在这个名称的视图时返回nil
+func readView(viewName string) (view *Sql, err error) {
+	fileName, err := getObjectFileByName(viewName + ".txt")
+	if err != nil || fileName == "" {
+		return nil, err
+	}
+	bytes, err := ioutil.ReadFile("./file/" + fileName)
+	if err != nil {
+		return nil, err
+	}
+	definition := strings.TrimSpace(string(bytes))
+	// 旧的视图文件中只保存了SELECT语句
+	if !strings.HasPrefix(strings.ToUpper(definition), "CREATE") {
+		definition = "CREATE VIEW " + viewName + " AS " + definition
+	}
+	sql, err := (&parser{
+		sql:      definition,
+		position: 0,
+		query:    Sql{},
+		step:     stepBeginning,
+		err:      nil,
+	}).doParse()
+	if err != nil {
+		return nil, fmt.Errorf("at view %s: %v", viewName, err)
+	}
+	return &sql, nil
+}
+
+// 读取用户文件，用户文件不存在时返回nil
+func readUsersJson() (users *UsersJson, err error) {
+	fileName, err := getFileByName("users.json")
+	if err != nil || fileName == "" {
+		return nil, err
+	}
+	bytes, err := ioutil.ReadFile("./file/" + fileName)
+	if err != nil {
+		return nil, err
+	}
+	users = &UsersJson{}
+	err = json.Unmarshal(bytes, users)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// 把用户和权限写入用户文件
+func writeUsersJson(users *UsersJson) (err error) {
+	jsonUsers, err := json.Marshal(users)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile("./file/users.json", jsonUsers, os.ModeAppend)
+}
+
+// 找到索引的文件：索引名为空时是表上的全部索引，表名为空时是所有表上的这个名称的索引
+func getIndexFiles(indexName string, tableName string) (files []string, err error) {
+	dir, err := ioutil.ReadDir("./file")
+	if err != nil {
+		return nil, err
+	}
+	// 索引文件名：索引名_表名_idx_ASC_列名.json
+	for _, file := range dir {
+		name := file.Name()
+		if !strings.Contains(name, "_idx_") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		if indexName != "" && !strings.HasPrefix(name, indexName+"_") {
+			continue
+		}
+		if tableName != "" && !strings.Contains(name, "_"+tableName+"_idx_") {
+			continue
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+// 从索引文件名中取出用户给出的索引名，tableName是索引所在的表
+func indexNameOfFile(fileName string, tableName string) string {
+	if end := strings.Index(fileName, "_"+tableName+"_idx_"); end >= 0 {
+		return fileName[:end]
+	}
+	return strings.TrimSuffix(fileName, ".json")
 }
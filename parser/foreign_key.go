@@ -0,0 +1,355 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 一条语句修改的所有表：级联删除和级联更新会修改参照被修改的表的其他表
+// 所有的修改都在内存中进行，全部检查通过之后再统一写入文件
+type tableChanges struct {
+	operation string                  // 语句的类型，用于错误信息
+	tables    map[string]*TableJson   // 已经读取的表，同一个表只读取一次
+	deleted   map[string]map[int]bool // 每个表中要删除的行，写入文件时才真正删除，保证行的下标不变
+	changed   map[string]bool         // 被修改过的表
+}
+
+// 一个外键：table表的第field列参照另一个表中的一列
+type foreignKey struct {
+	table *TableJson
+	field int
+}
+
+func newTableChanges(operation string, table *TableJson) *tableChanges {
+	return &tableChanges{
+		operation: operation,
+		tables:    map[string]*TableJson{table.Name: table},
+		deleted:   map[string]map[int]bool{},
+		changed:   map[string]bool{},
+	}
+}
+
+// 读取表，已经读取过的表直接返回内存中的表
+func (changes *tableChanges) table(name string) (table *TableJson, err error) {
+	if table, ok := changes.tables[name]; ok {
+		return table, nil
+	}
+	table, err = readTableJson(name)
+	if err != nil {
+		return nil, err
+	}
+	changes.tables[name] = table
+	return table, nil
+}
+
+// 参照tableName表fieldName列的所有外键
+func (changes *tableChanges) referencingKeys(tableName string, fieldName string) (keys []foreignKey, err error) {
+	tables, _, _, _, err := getFilesForHelpDataBase()
+	if err != nil {
+		return nil, err
+	}
+	for _, fileName := range tables {
+		table, err := changes.table(strings.TrimSuffix(fileName, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		for index, field := range table.Fields {
+			if field.ForeignKey && field.ForeignKeyTable == tableName && field.ForeignKeyColumn == fieldName {
+				keys = append(keys, foreignKey{table: table, field: index})
+			}
+		}
+	}
+	return keys, nil
+}
+
+// 表中第fieldIndex列在没有被删除的行中的所有值
+func (changes *tableChanges) values(table *TableJson, fieldIndex int) map[string]bool {
+	values := map[string]bool{}
+	for row, value := range table.Fields[fieldIndex].Data {
+		if !changes.deleted[table.Name][row] && value != NullValue {
+			values[value] = true
+		}
+	}
+	return values
+}
+
+// 删除或者更新之后不再出现在列中的旧值，参照这些值的行需要按照参照动作处理
+func (changes *tableChanges) removedValues(table *TableJson, fieldIndex int, oldValues []string) (removed map[string]bool) {
+	remain := changes.values(table, fieldIndex)
+	removed = map[string]bool{}
+	for _, value := range oldValues {
+		if value != NullValue && !remain[value] {
+			removed[value] = true
+		}
+	}
+	return removed
+}
+
+// 外键所在的表中参照了被参照列referenced中removed的值的行
+func (changes *tableChanges) referencingRows(key foreignKey, referenced FieldJson, removed map[string]bool) (rows []int) {
+	child := key.table.Fields[key.field]
+	for row, value := range child.Data {
+		if !changes.deleted[key.table.Name][row] && removed[referencedValue(value, child, referenced)] {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// 删除表中的一些行，并按照参照这些行的外键的ON DELETE动作处理参照它们的行
+func (changes *tableChanges) deleteRows(table *TableJson, rows []int) (err error) {
+	if changes.deleted[table.Name] == nil {
+		changes.deleted[table.Name] = map[int]bool{}
+	}
+	var deletedRows []int
+	for _, row := range rows {
+		if !changes.deleted[table.Name][row] {
+			changes.deleted[table.Name][row] = true
+			deletedRows = append(deletedRows, row)
+		}
+	}
+	if len(deletedRows) == 0 {
+		return nil
+	}
+	changes.changed[table.Name] = true
+	for fieldIndex, field := range table.Fields {
+		var oldValues []string
+		for _, row := range deletedRows {
+			if row < len(field.Data) {
+				oldValues = append(oldValues, field.Data[row])
+			}
+		}
+		removed := changes.removedValues(table, fieldIndex, oldValues)
+		if len(removed) == 0 {
+			continue
+		}
+		keys, err := changes.referencingKeys(table.Name, field.Name)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			referencing := changes.referencingRows(key, field, removed)
+			if len(referencing) == 0 {
+				continue
+			}
+			child := key.table.Fields[key.field]
+			switch child.OnDelete {
+			case Cascade:
+				err = changes.deleteRows(key.table, referencing)
+			case SetNull, SetDefault:
+				var updates []string
+				if updates, err = referentialValues(child, child.OnDelete, len(referencing), changes.operation); err != nil {
+					return err
+				}
+				err = changes.updateValues(key.table, key.field, referencing, updates)
+				// SET DEFAULT设置的默认值也必须在被参照的列中出现
+				if err == nil && child.OnDelete == SetDefault {
+					err = changes.checkReferences(key.table, referencing, []string{child.Name})
+				}
+			default:
+				return fmt.Errorf("at %s: %s.%s %s is still referenced by %s.%s",
+					changes.operation, table.Name, field.Name, referencedValue(child.Data[referencing[0]], child, field), key.table.Name, child.Name)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 把表中一些行的第fieldIndex列改为新的值，并按照参照这一列的外键的ON UPDATE动作处理参照旧值的行
+func (changes *tableChanges) updateValues(table *TableJson, fieldIndex int, rows []int, values []string) (err error) {
+	field := &table.Fields[fieldIndex]
+	// 该列的数据比其他列少，先用空值补齐
+	for rowCount := tableRowCount(table); len(field.Data) < rowCount; {
+		field.Data = append(field.Data, NullValue)
+	}
+	changes.changed[table.Name] = true
+	// 旧值对应的新值，用于级联更新
+	newValues := map[string]string{}
+	var oldValues []string
+	for i, row := range rows {
+		oldValue := field.Data[row]
+		if oldValue == values[i] {
+			continue
+		}
+		field.Data[row] = values[i]
+		if _, ok := newValues[oldValue]; !ok {
+			newValues[oldValue] = values[i]
+		}
+		oldValues = append(oldValues, oldValue)
+	}
+	removed := changes.removedValues(table, fieldIndex, oldValues)
+	if len(removed) == 0 {
+		return nil
+	}
+	keys, err := changes.referencingKeys(table.Name, field.Name)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		referencing := changes.referencingRows(key, *field, removed)
+		if len(referencing) == 0 {
+			continue
+		}
+		child := key.table.Fields[key.field]
+		updates := make([]string, len(referencing))
+		switch child.OnUpdate {
+		case Cascade:
+			// 新值是被参照列的类型，转换为外键列的类型
+			for i, row := range referencing {
+				updates[i], err = convertValue(newValues[referencedValue(child.Data[row], child, *field)], child.DataType, child.DataLength, child.Scale)
+				if err != nil {
+					return fmt.Errorf("at %s: cannot cascade to %s.%s: %v", changes.operation, key.table.Name, child.Name, err)
+				}
+			}
+		case SetNull, SetDefault:
+			if updates, err = referentialValues(child, child.OnUpdate, len(referencing), changes.operation); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("at %s: %s.%s %s is still referenced by %s.%s",
+				changes.operation, table.Name, field.Name, referencedValue(child.Data[referencing[0]], child, *field), key.table.Name, child.Name)
+		}
+		for _, update := range updates {
+			if update == NullValue && (child.NotNull || child.PrimaryKey) {
+				return fmt.Errorf("at %s: cannot set NOT NULL field %s.%s to null", changes.operation, key.table.Name, child.Name)
+			}
+		}
+		if err = changes.updateValues(key.table, key.field, referencing, updates); err != nil {
+			return err
+		}
+		// SET DEFAULT设置的默认值也必须在被参照的列中出现
+		if child.OnUpdate == SetDefault {
+			if err = changes.checkReferences(key.table, referencing, []string{child.Name}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 参照动作SET NULL和SET DEFAULT设置的新值：空值或者外键所在列的默认值
+func referentialValues(field FieldJson, action ReferentialAction, count int, operation string) (values []string, err error) {
+	value := NullValue
+	if action == SetDefault {
+		if value, err = defaultValue(field); err != nil {
+			return nil, fmt.Errorf("at %s: %v", operation, err)
+		}
+	}
+	if value == NullValue && (field.NotNull || field.PrimaryKey) {
+		return nil, fmt.Errorf("at %s: cannot set NOT NULL field %s to null", operation, field.Name)
+	}
+	values = make([]string, count)
+	for i := range values {
+		values[i] = value
+	}
+	return values, nil
+}
+
+// 检查表中一些行的外键：外键的值必须在被参照列中出现，fields为空时检查所有的外键
+func (changes *tableChanges) checkReferences(table *TableJson, rows []int, fields []string) error {
+	for _, field := range table.Fields {
+		if !field.ForeignKey || (len(fields) > 0 && !containsString(fields, field.Name)) {
+			continue
+		}
+		referenced, err := changes.table(field.ForeignKeyTable)
+		if err != nil {
+			return fmt.Errorf("at %s: %v", changes.operation, err)
+		}
+		referencedIndex := tableFieldIndex(referenced, field.ForeignKeyColumn)
+		if referencedIndex < 0 {
+			return fmt.Errorf("at %s: unknown field %s in table %s", changes.operation, field.ForeignKeyColumn, referenced.Name)
+		}
+		values := changes.values(referenced, referencedIndex)
+		for _, row := range rows {
+			if row < len(field.Data) && field.Data[row] != NullValue && !values[referencedValue(field.Data[row], field, referenced.Fields[referencedIndex])] {
+				return fmt.Errorf("at %s: value %s of field %s is not in %s(%s)",
+					changes.operation, field.Data[row], field.Name, referenced.Name, field.ForeignKeyColumn)
+			}
+		}
+	}
+	return nil
+}
+
+// 删除标记为删除的行，把所有修改过的表写入文件
+func (changes *tableChanges) write() {
+	for name := range changes.changed {
+		table := changes.tables[name]
+		for index, field := range table.Fields {
+			remain := []string{}
+			for row, data := range field.Data {
+				if !changes.deleted[name][row] {
+					remain = append(remain, data)
+				}
+			}
+			table.Fields[index].Data = remain
+		}
+		if err := writeTableJson(table); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// 检查建表语句中的外键：被参照的表和列必须存在，参照本表时被参照的列必须在本表中
+func checkCreateForeignKeys(table *TableJson) error {
+	for _, field := range table.Fields {
+		if !field.ForeignKey {
+			continue
+		}
+		referenced := table
+		if field.ForeignKeyTable != table.Name {
+			objectType, err := getObjectType(field.ForeignKeyTable)
+			if err != nil {
+				return err
+			}
+			if objectType != DropTable {
+				return fmt.Errorf("at CREATE TABLE: referenced table %s of foreign key %s does not exist", field.ForeignKeyTable, field.Name)
+			}
+			if referenced, err = readTableJson(field.ForeignKeyTable); err != nil {
+				return fmt.Errorf("at CREATE TABLE: %v", err)
+			}
+		}
+		referencedIndex := tableFieldIndex(referenced, field.ForeignKeyColumn)
+		if referencedIndex < 0 {
+			return fmt.Errorf("at CREATE TABLE: referenced field %s of foreign key %s does not exist in table %s",
+				field.ForeignKeyColumn, field.Name, referenced.Name)
+		}
+		if !isKeyField(referenced, field.ForeignKeyColumn) {
+			return fmt.Errorf("at CREATE TABLE: referenced field %s(%s) of foreign key %s is not a PRIMARY KEY or UNIQUE field",
+				referenced.Name, field.ForeignKeyColumn, field.Name)
+		}
+		if err := checkForeignKeyType(field, referenced.Fields[referencedIndex], referenced.Name); err != nil {
+			return fmt.Errorf("at CREATE TABLE: %v", err)
+		}
+	}
+	return nil
+}
+
+// 外键列与被参照列的数据类型必须能够比较：相同的类型，或者都是数值类型，或者都是字符串类型
+func checkForeignKeyType(field FieldJson, referenced FieldJson, referencedTable string) error {
+	if field.DataType == referenced.DataType ||
+		(isNumberType(field.DataType) && isNumberType(referenced.DataType)) ||
+		(isStringType(field.DataType) && isStringType(referenced.DataType)) {
+		return nil
+	}
+	return fmt.Errorf("type %s of foreign key %s does not match type %s of referenced field %s(%s)",
+		dataTypeName(field.DataType, field.DataLength, field.Scale), field.Name,
+		dataTypeName(referenced.DataType, referenced.DataLength, referenced.Scale), referencedTable, referenced.Name)
+}
+
+// 外键列的值转换为被参照列的数据类型之后再与被参照列中的值比较，例如DECIMAL的1.00与SMALLINT的1相同
+// CHAR末尾补齐的空格不算在值中，无法转换的值不会出现在被参照列中，返回原值
+func referencedValue(value string, field FieldJson, referenced FieldJson) string {
+	if value == NullValue {
+		return value
+	}
+	if field.DataType == Char {
+		value = strings.TrimRight(value, " ")
+	}
+	if converted, err := convertValue(value, referenced.DataType, referenced.DataLength, referenced.Scale); err == nil {
+		return converted
+	}
+	return value
+}
@@ -0,0 +1,656 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 内置的标量函数
+type scalarFunction struct {
+	minArguments  int            // 最少的参数个数
+	maxArguments  int            // 最多的参数个数，为-1时不限制
+	argumentTypes []argumentType // 每个参数要求的类型，参数比这里多时，多出的参数使用最后一个类型
+	acceptsNull   bool           // 参数中有空值时是否仍然调用函数，为false时结果直接为空值
+	// 根据参数的类型得到返回值的类型
+	returnType func(argumentTypes []DataType) DataType
+	// 计算函数的值，参数都已经计算完成
+	call func(arguments []string, argumentTypes []DataType) (string, error)
+}
+
+// 函数参数要求的类型
+type argumentType int
+
+const (
+	anyArgument      argumentType = iota // 任意类型
+	stringArgument                       // 字符串：VARCHAR、CHAR或者TEXT
+	numberArgument                       // 数值：SMALLINT、BIGINT、DOUBLE或者DECIMAL
+	integerArgument                      // 整数：SMALLINT或者BIGINT
+	dateTimeArgument                     // 日期时间：DATETIME、DATE或者TIME
+)
+
+var argumentTypeString = []string{
+	"any type",
+	"VARCHAR, CHAR or TEXT",
+	"SMALLINT, BIGINT, DOUBLE or DECIMAL",
+	"SMALLINT or BIGINT",
+	"DATETIME, DATE or TIME",
+}
+
+// 判断该类型的值能否作为参数，没有确定类型的值（列的类型还不知道时）在计算时再检查
+// 带单引号的字面值由checkLiteralArguments检查
+func (argument argumentType) accepts(dataType DataType) bool {
+	if dataType == UnknownDataType {
+		return true
+	}
+	switch argument {
+	case stringArgument:
+		return isStringType(dataType)
+	case numberArgument:
+		return isNumberType(dataType)
+	case integerArgument:
+		return isIntegerType(dataType)
+	case dateTimeArgument:
+		return isDateTimeType(dataType)
+	default:
+		return true
+	}
+}
+
+// 日期时间的单位，用于DATE_ADD和EXTRACT
+var dateTimeUnits = []string{"YEAR", "MONTH", "DAY", "HOUR", "MINUTE", "SECOND"}
+
+// 所有的内置标量函数，函数名为大写
+var scalarFunctions = map[string]*scalarFunction{
+	// 字符串函数
+	"UPPER": {
+		minArguments: 1, maxArguments: 1, argumentTypes: []argumentType{stringArgument},
+		returnType: fixedType(Varchar),
+		call: func(arguments []string, _ []DataType) (string, error) {
+			return strings.ToUpper(arguments[0]), nil
+		},
+	},
+	"LOWER": {
+		minArguments: 1, maxArguments: 1, argumentTypes: []argumentType{stringArgument},
+		returnType: fixedType(Varchar),
+		call: func(arguments []string, _ []DataType) (string, error) {
+			return strings.ToLower(arguments[0]), nil
+		},
+	},
+	"LENGTH": {
+		// 按字符计算长度，一个汉字是一个字符
+		minArguments: 1, maxArguments: 1, argumentTypes: []argumentType{stringArgument},
+		returnType: fixedType(SmallInt),
+		call: func(arguments []string, _ []DataType) (string, error) {
+			return strconv.Itoa(utf8.RuneCountInString(arguments[0])), nil
+		},
+	},
+	"SUBSTRING": {
+		// SUBSTRING(字符串, 开始位置[, 长度])，位置从1开始
+		minArguments: 2, maxArguments: 3, argumentTypes: []argumentType{stringArgument, integerArgument, integerArgument},
+		returnType: fixedType(Varchar),
+		call: func(arguments []string, _ []DataType) (string, error) {
+			runes := []rune(arguments[0])
+			start, err := toInteger("SUBSTRING", arguments[1])
+			if err != nil {
+				return "", err
+			}
+			end := len(runes) + 1
+			if len(arguments) > 2 {
+				length, err := toInteger("SUBSTRING", arguments[2])
+				if err != nil {
+					return "", err
+				}
+				if length < 0 {
+					return "", fmt.Errorf("at SUBSTRING: negative length %d", length)
+				}
+				end = start + length
+			}
+			// 超出字符串范围的部分不取
+			if start < 1 {
+				start = 1
+			}
+			if end > len(runes)+1 {
+				end = len(runes) + 1
+			}
+			if end <= start {
+				return "", nil
+			}
+			return string(runes[start-1 : end-1]), nil
+		},
+	},
+	"TRIM": {
+		// 去掉字符串两端的空格
+		minArguments: 1, maxArguments: 1, argumentTypes: []argumentType{stringArgument},
+		returnType: fixedType(Varchar),
+		call: func(arguments []string, _ []DataType) (string, error) {
+			return strings.Trim(arguments[0], " "), nil
+		},
+	},
+	"REPLACE": {
+		// REPLACE(字符串, 被替换的子串, 替换成的子串)
+		minArguments: 3, maxArguments: 3, argumentTypes: []argumentType{stringArgument},
+		returnType: fixedType(Varchar),
+		call: func(arguments []string, _ []DataType) (string, error) {
+			if arguments[1] == "" {
+				return arguments[0], nil
+			}
+			return strings.ReplaceAll(arguments[0], arguments[1], arguments[2]), nil
+		},
+	},
+	"CONCAT": {
+		// 依次连接所有参数，空值的参数被忽略；||运算中有空值时结果为空值
+		minArguments: 1, maxArguments: -1, argumentTypes: []argumentType{anyArgument}, acceptsNull: true,
+		returnType: fixedType(Varchar),
+		call: func(arguments []string, _ []DataType) (string, error) {
+			var builder strings.Builder
+			for _, argument := range arguments {
+				if argument != NullValue {
+					builder.WriteString(argument)
+				}
+			}
+			return builder.String(), nil
+		},
+	},
+
+	// 数值函数
+	"ABS": {
+		minArguments: 1, maxArguments: 1, argumentTypes: []argumentType{numberArgument},
+		returnType: firstNumberType,
+		call: func(arguments []string, argumentTypes []DataType) (string, error) {
+			number, dataType, err := toNumber(arguments[0], argumentTypes[0])
+			if err != nil {
+				return "", fmt.Errorf("at ABS: %v", err)
+			}
+			if dataType == Decimal || isIntegerType(dataType) {
+				// 精确的数值直接去掉负号
+				return strings.TrimPrefix(arguments[0], "-"), nil
+			}
+			return formatNumber(math.Abs(number), dataType), nil
+		},
+	},
+	"ROUND": {
+		// ROUND(数值[, 小数位数])，四舍五入，小数位数默认为0
+		minArguments: 1, maxArguments: 2, argumentTypes: []argumentType{numberArgument, integerArgument},
+		returnType: firstNumberType,
+		call: func(arguments []string, argumentTypes []DataType) (string, error) {
+			number, dataType, err := toNumber(arguments[0], argumentTypes[0])
+			if err != nil {
+				return "", fmt.Errorf("at ROUND: %v", err)
+			}
+			digits := 0
+			if len(arguments) > 1 {
+				digits, err = toInteger("ROUND", arguments[1])
+				if err != nil {
+					return "", err
+				}
+			}
+			if dataType == Decimal && digits >= 0 {
+				// DECIMAL按十进制精确地四舍五入
+				decimal, err := parseDecimal(arguments[0])
+				if err != nil {
+					return "", fmt.Errorf("at ROUND: %v", err)
+				}
+				return decimal.FloatString(digits), nil
+			}
+			scale := math.Pow(10, float64(digits))
+			return formatNumber(math.Round(number*scale)/scale, dataType), nil
+		},
+	},
+	"CEIL": {
+		minArguments: 1, maxArguments: 1, argumentTypes: []argumentType{numberArgument},
+		returnType: firstNumberType,
+		call: func(arguments []string, argumentTypes []DataType) (string, error) {
+			number, dataType, err := toNumber(arguments[0], argumentTypes[0])
+			if err != nil {
+				return "", fmt.Errorf("at CEIL: %v", err)
+			}
+			if dataType == Decimal {
+				return roundDecimal(arguments[0], true)
+			}
+			return formatNumber(math.Ceil(number), dataType), nil
+		},
+	},
+	"FLOOR": {
+		minArguments: 1, maxArguments: 1, argumentTypes: []argumentType{numberArgument},
+		returnType: firstNumberType,
+		call: func(arguments []string, argumentTypes []DataType) (string, error) {
+			number, dataType, err := toNumber(arguments[0], argumentTypes[0])
+			if err != nil {
+				return "", fmt.Errorf("at FLOOR: %v", err)
+			}
+			if dataType == Decimal {
+				return roundDecimal(arguments[0], false)
+			}
+			return formatNumber(math.Floor(number), dataType), nil
+		},
+	},
+	"MOD": {
+		// MOD(a, b)与a % b相同
+		minArguments: 2, maxArguments: 2, argumentTypes: []argumentType{numberArgument},
+		returnType: func(argumentTypes []DataType) DataType {
+			return arithmeticDataType("%", argumentTypes[0], argumentTypes[1])
+		},
+		call: func(arguments []string, argumentTypes []DataType) (string, error) {
+			value, _, err := arithmetic("%", arguments[0], argumentTypes[0], arguments[1], argumentTypes[1])
+			if err != nil {
+				return "", fmt.Errorf("at MOD: %v", err)
+			}
+			return value, nil
+		},
+	},
+	"FORMAT": {
+		// FORMAT(数值, 小数位数)，格式化为保留固定小数位数的字符串
+		minArguments: 2, maxArguments: 2, argumentTypes: []argumentType{numberArgument, integerArgument},
+		returnType: fixedType(Varchar),
+		call: func(arguments []string, argumentTypes []DataType) (string, error) {
+			number, _, err := toNumber(arguments[0], argumentTypes[0])
+			if err != nil {
+				return "", fmt.Errorf("at FORMAT: %v", err)
+			}
+			digits, err := toInteger("FORMAT", arguments[1])
+			if err != nil {
+				return "", err
+			}
+			if digits < 0 {
+				digits = 0
+			}
+			return strconv.FormatFloat(number, 'f', digits, 64), nil
+		},
+	},
+
+	// 空值处理函数
+	"COALESCE": {
+		// 返回第一个不是空值的参数，都是空值时结果为空值
+		minArguments: 1, maxArguments: -1, argumentTypes: []argumentType{anyArgument}, acceptsNull: true,
+		returnType: commonType,
+		call: func(arguments []string, _ []DataType) (string, error) {
+			for _, argument := range arguments {
+				if argument != NullValue {
+					return argument, nil
+				}
+			}
+			return NullValue, nil
+		},
+	},
+	"NULLIF": {
+		// NULLIF(a, b)：a与b相等时结果为空值，否则为a
+		minArguments: 2, maxArguments: 2, argumentTypes: []argumentType{anyArgument}, acceptsNull: true,
+		returnType: func(argumentTypes []DataType) DataType {
+			return argumentTypes[0]
+		},
+		call: func(arguments []string, argumentTypes []DataType) (string, error) {
+			if arguments[0] == NullValue || arguments[1] == NullValue {
+				return arguments[0], nil
+			}
+			cmp, err := compareValues(arguments[0], arguments[1], comparisonDataType(argumentTypes[0], argumentTypes[1]))
+			if err != nil {
+				return "", fmt.Errorf("at NULLIF: %v", err)
+			}
+			if cmp == 0 {
+				return NullValue, nil
+			}
+			return arguments[0], nil
+		},
+	},
+
+	// 日期时间函数
+	"NOW": {
+		// 当前的日期时间
+		minArguments: 0, maxArguments: 0,
+		returnType: fixedType(DateTime),
+		call: func(_ []string, _ []DataType) (string, error) {
+			return time.Now().Format(dateTimeLayout), nil
+		},
+	},
+	"DATE_ADD": {
+		// DATE_ADD(日期时间, 数量[, 单位])，也可以写成DATE_ADD(日期时间, INTERVAL 数量 单位)，单位默认为DAY
+		minArguments: 2, maxArguments: 3, argumentTypes: []argumentType{dateTimeArgument, integerArgument, stringArgument},
+		returnType: fixedType(DateTime),
+		call: func(arguments []string, _ []DataType) (string, error) {
+			t, err := toDateTime("DATE_ADD", arguments[0])
+			if err != nil {
+				return "", err
+			}
+			amount, err := toInteger("DATE_ADD", arguments[1])
+			if err != nil {
+				return "", err
+			}
+			unit := "DAY"
+			if len(arguments) > 2 {
+				unit = strings.ToUpper(arguments[2])
+			}
+			switch unit {
+			case "YEAR":
+				t = addMonths(t, amount*12)
+			case "MONTH":
+				t = addMonths(t, amount)
+			case "DAY":
+				t = t.AddDate(0, 0, amount)
+			case "HOUR":
+				t = t.Add(time.Duration(amount) * time.Hour)
+			case "MINUTE":
+				t = t.Add(time.Duration(amount) * time.Minute)
+			case "SECOND":
+				t = t.Add(time.Duration(amount) * time.Second)
+			default:
+				return "", fmt.Errorf("at DATE_ADD: unknown unit %s", unit)
+			}
+			return t.Format(dateTimeLayout), nil
+		},
+	},
+	"DATEDIFF": {
+		// DATEDIFF(a, b)：a与b相差的天数，只比较日期部分
+		minArguments: 2, maxArguments: 2, argumentTypes: []argumentType{dateTimeArgument},
+		returnType: fixedType(SmallInt),
+		call: func(arguments []string, _ []DataType) (string, error) {
+			a, err := toDateTime("DATEDIFF", arguments[0])
+			if err != nil {
+				return "", err
+			}
+			b, err := toDateTime("DATEDIFF", arguments[1])
+			if err != nil {
+				return "", err
+			}
+			a = time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+			b = time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+			return strconv.Itoa(int(a.Sub(b).Hours() / 24)), nil
+		},
+	},
+	"EXTRACT": {
+		// EXTRACT(单位 FROM 日期时间)，解析后第一个参数是单位
+		minArguments: 2, maxArguments: 2, argumentTypes: []argumentType{stringArgument, dateTimeArgument},
+		returnType: fixedType(SmallInt),
+		call: func(arguments []string, _ []DataType) (string, error) {
+			t, err := toDateTime("EXTRACT", arguments[1])
+			if err != nil {
+				return "", err
+			}
+			switch strings.ToUpper(arguments[0]) {
+			case "YEAR":
+				return strconv.Itoa(t.Year()), nil
+			case "MONTH":
+				return strconv.Itoa(int(t.Month())), nil
+			case "DAY":
+				return strconv.Itoa(t.Day()), nil
+			case "HOUR":
+				return strconv.Itoa(t.Hour()), nil
+			case "MINUTE":
+				return strconv.Itoa(t.Minute()), nil
+			case "SECOND":
+				return strconv.Itoa(t.Second()), nil
+			default:
+				return "", fmt.Errorf("at EXTRACT: unknown unit %s", arguments[0])
+			}
+		},
+	},
+	"DATE_FORMAT": {
+		// DATE_FORMAT(日期时间, 格式)，格式中的%Y、%m、%d、%H、%i、%s等替换为对应的部分
+		minArguments: 2, maxArguments: 2, argumentTypes: []argumentType{dateTimeArgument, stringArgument},
+		returnType: fixedType(Varchar),
+		call: func(arguments []string, _ []DataType) (string, error) {
+			t, err := toDateTime("DATE_FORMAT", arguments[0])
+			if err != nil {
+				return "", err
+			}
+			return formatDateTime(t, arguments[1]), nil
+		},
+	},
+}
+
+// 返回值为固定类型
+func fixedType(dataType DataType) func([]DataType) DataType {
+	return func([]DataType) DataType {
+		return dataType
+	}
+}
+
+// 返回值与第一个参数的数值类型相同，类型不确定时为DOUBLE
+func firstNumberType(argumentTypes []DataType) DataType {
+	if isNumberType(argumentTypes[0]) {
+		return argumentTypes[0]
+	}
+	return Double
+}
+
+// 把DECIMAL的值向上（ceil为true）或者向下取整
+func roundDecimal(value string, ceil bool) (rounded string, err error) {
+	decimal, err := parseDecimal(value)
+	if err != nil {
+		return "", err
+	}
+	// Int.Div是欧几里得除法，除数为正数时结果就是向下取整
+	result := new(big.Int).Div(decimal.Num(), decimal.Denom())
+	if ceil && !decimal.IsInt() {
+		result.Add(result, big.NewInt(1))
+	}
+	return result.String(), nil
+}
+
+// 返回值为所有参数的共同类型
+func commonType(argumentTypes []DataType) (dataType DataType) {
+	for _, argumentType := range argumentTypes {
+		dataType = comparisonDataType(dataType, argumentType)
+	}
+	return dataType
+}
+
+// 检查函数的参数个数和类型，返回函数返回值的类型
+func functionDataType(name string, argumentTypes []DataType) (dataType DataType, err error) {
+	function, ok := scalarFunctions[name]
+	if !ok {
+		return UnknownDataType, fmt.Errorf("unknown function %s", name)
+	}
+	if len(argumentTypes) < function.minArguments || (function.maxArguments >= 0 && len(argumentTypes) > function.maxArguments) {
+		return UnknownDataType, fmt.Errorf("at %s: wrong number of arguments %d", name, len(argumentTypes))
+	}
+	for index, dataType := range argumentTypes {
+		expected := function.argumentTypes[min(index, len(function.argumentTypes)-1)]
+		if !expected.accepts(dataType) {
+			return UnknownDataType, fmt.Errorf("at %s: argument %d must be %s, got %s",
+				name, index+1, argumentTypeString[expected], DataTypeString[dataType])
+		}
+	}
+	return function.returnType(argumentTypes), nil
+}
+
+// 带单引号的字面值的数据类型：能解析为日期、日期时间或者时间的是对应的类型，其他的都是字符串
+func literalDataType(value string) DataType {
+	if _, err := time.Parse(dateLayout, value); err == nil {
+		return Date
+	}
+	for _, dataType := range []DataType{DateTime, Time} {
+		if _, err := convertValue(value, dataType, 0, 0); err == nil {
+			return dataType
+		}
+	}
+	return Varchar
+}
+
+// 检查带单引号的字面值参数的类型，空值可以作为任何参数
+// 字面值都可以作为字符串参数，其他参数按照literalDataType得到的类型检查，例如ABS('x')和DATEDIFF('abc', Sbirth)都是错误的
+func checkLiteralArguments(name string, arguments []*Expression) error {
+	function := scalarFunctions[name]
+	for index, argument := range arguments {
+		if argument.Type != LiteralExpression || !argument.Quoted || argument.Value == NullValue {
+			continue
+		}
+		expected := function.argumentTypes[min(index, len(function.argumentTypes)-1)]
+		if dataType := literalDataType(argument.Value); expected != stringArgument && !expected.accepts(dataType) {
+			return fmt.Errorf("at %s: argument %d must be %s, got %s '%s'",
+				name, index+1, argumentTypeString[expected], DataTypeString[dataType], argument.Value)
+		}
+	}
+	return nil
+}
+
+// 调用函数，返回函数的值和类型
+func callFunction(name string, arguments []string, argumentTypes []DataType) (value string, dataType DataType, err error) {
+	dataType, err = functionDataType(name, argumentTypes)
+	if err != nil {
+		return "", UnknownDataType, err
+	}
+	function := scalarFunctions[name]
+	// 参数中有空值，结果为空值
+	if !function.acceptsNull {
+		for _, argument := range arguments {
+			if argument == NullValue {
+				return NullValue, dataType, nil
+			}
+		}
+	}
+	value, err = function.call(arguments, argumentTypes)
+	if err != nil {
+		return "", UnknownDataType, err
+	}
+	return value, dataType, nil
+}
+
+// 解析标量函数的参数部分：(参数, 参数, ...)
+// EXTRACT(单位 FROM 日期时间)和DATE_ADD(日期时间, INTERVAL 数量 单位)有特殊的写法
+func (p *parser) parseFunction(name string) (expression *Expression, err error) {
+	if _, ok := scalarFunctions[name]; !ok {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+	// 弹出左括号
+	p.pop()
+	expression = &Expression{Type: FunctionExpression, Function: name}
+	if name == "EXTRACT" {
+		unit := strings.ToUpper(p.pop())
+		if !containsString(dateTimeUnits, unit) {
+			return nil, fmt.Errorf("at EXTRACT: unknown unit %s", unit)
+		}
+		if strings.ToUpper(p.pop()) != "FROM" {
+			return nil, fmt.Errorf("at EXTRACT: expected FROM")
+		}
+		argument, _, err := p.parseExpression()
+		if err != nil {
+			return nil, fmt.Errorf("at EXTRACT: %v", err)
+		}
+		expression.Arguments = []*Expression{{Type: LiteralExpression, Value: unit, Quoted: true}, argument}
+	} else if p.peek() != ")" {
+		for {
+			if name == "DATE_ADD" && len(expression.Arguments) == 1 && strings.ToUpper(p.peek()) == "INTERVAL" {
+				p.pop()
+				amount, _, err := p.parseExpression()
+				if err != nil {
+					return nil, fmt.Errorf("at DATE_ADD: %v", err)
+				}
+				unit := strings.ToUpper(p.pop())
+				if !containsString(dateTimeUnits, unit) {
+					return nil, fmt.Errorf("at DATE_ADD: unknown unit %s", unit)
+				}
+				expression.Arguments = append(expression.Arguments, amount, &Expression{Type: LiteralExpression, Value: unit, Quoted: true})
+			} else {
+				argument, _, err := p.parseExpression()
+				if err != nil {
+					return nil, fmt.Errorf("at %s: %v", name, err)
+				}
+				expression.Arguments = append(expression.Arguments, argument)
+			}
+			if p.peek() != "," {
+				break
+			}
+			p.pop()
+		}
+	}
+	if p.peek() != ")" {
+		return nil, fmt.Errorf("at %s: expected closing parens ')'", name)
+	}
+	p.pop()
+	// 解析时就检查参数的个数，以及已经能够确定类型的参数的类型，列的类型在执行前再检查
+	if _, err := expression.dataType(nil); err != nil {
+		return nil, err
+	}
+	return expression, nil
+}
+
+// 把函数的参数转换为整数
+func toInteger(functionName string, value string) (number int, err error) {
+	number, err = strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("at %s: %s is not an integer", functionName, value)
+	}
+	return number, nil
+}
+
+// 把函数的参数转换为日期时间，也可以只有日期部分或者时间部分
+func toDateTime(functionName string, value string) (t time.Time, err error) {
+	t, err = time.Parse(dateTimeLayout, value)
+	if err == nil {
+		return t, nil
+	}
+	t, err = time.Parse(dateLayout, value)
+	if err == nil {
+		return t, nil
+	}
+	t, err = time.Parse(timeLayout, value)
+	if err == nil {
+		return t, nil
+	}
+	return t, fmt.Errorf("at %s: %s is not a DATETIME", functionName, value)
+}
+
+// 按照DATE_FORMAT的格式格式化日期时间
+func formatDateTime(t time.Time, format string) string {
+	var builder strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			builder.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'Y':
+			builder.WriteString(t.Format("2006"))
+		case 'y':
+			builder.WriteString(t.Format("06"))
+		case 'm':
+			builder.WriteString(t.Format("01"))
+		case 'c':
+			builder.WriteString(strconv.Itoa(int(t.Month())))
+		case 'd':
+			builder.WriteString(t.Format("02"))
+		case 'e':
+			builder.WriteString(strconv.Itoa(t.Day()))
+		case 'H':
+			builder.WriteString(t.Format("15"))
+		case 'h':
+			builder.WriteString(t.Format("03"))
+		case 'i':
+			builder.WriteString(t.Format("04"))
+		case 's':
+			builder.WriteString(t.Format("05"))
+		case 'p':
+			builder.WriteString(t.Format("PM"))
+		case 'M':
+			builder.WriteString(t.Format("January"))
+		case 'b':
+			builder.WriteString(t.Format("Jan"))
+		case 'W':
+			builder.WriteString(t.Format("Monday"))
+		case 'a':
+			builder.WriteString(t.Format("Mon"))
+		default:
+			// %%以及不认识的格式符原样输出
+			builder.WriteByte(format[i])
+		}
+	}
+	return builder.String()
+}
+
+// 在日期时间上加若干个月，日期超出目标月份的天数时取该月的最后一天，例如1月31日加一个月是2月29日或者2月28日
+func addMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	// 目标月份的最后一天：下个月的第一天减去一天
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
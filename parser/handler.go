@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // 表的存储结构
 type TableJson struct {
-	Name   string      `json:"name"`
-	Fields []FieldJson `json:"fields"`
+	Name        string           `json:"name"`
+	Fields      []FieldJson      `json:"fields"`
+	Definition  string           `json:"definition,omitempty"`  // 物化视图的定义，普通的表为空
+	Constraints []ConstraintJson `json:"constraints,omitempty"` // 有名称的表级约束
 }
 
 // 列的存储结构
@@ -19,13 +23,68 @@ type FieldJson struct {
 	Name             string   `json:"name"`
 	DataType         DataType `json:"data_type"`
 	DataLength       int      `json:"data_length"`
+	Scale            int      `json:"scale,omitempty"` // DECIMAL的小数位数，DataLength为DECIMAL的精度
 	NotNull          bool     `json:"not_null"`
 	Unique           bool     `json:"unique"`
 	PrimaryKey       bool     `json:"primary_key"`
 	ForeignKey       bool     `json:"foreign_key"`
 	ForeignKeyTable  string   `json:"foreign_key_table"`
 	ForeignKeyColumn string   `json:"foreign_key_column"`
-	Data             []string `json:"data"`
+	// 外键的参照动作，默认为NO ACTION
+	OnDelete ReferentialAction `json:"on_delete,omitempty"`
+	OnUpdate ReferentialAction `json:"on_update,omitempty"`
+	// 默认值的表达式，插入时没有给出这一列的值时使用
+	Default string     `json:"default,omitempty"`
+	Data    ColumnData `json:"data"`
+}
+
+// 一列中的所有数据，空值NullValue在文件中存储为JSON的null
+type ColumnData []string
+
+// 写入文件时把空值转换为JSON的null
+func (data ColumnData) MarshalJSON() ([]byte, error) {
+	if data == nil {
+		return []byte("null"), nil
+	}
+	values := make([]*string, len(data))
+	for i := range data {
+		if data[i] != NullValue {
+			values[i] = &data[i]
+		}
+	}
+	return json.Marshal(values)
+}
+
+// 读取文件时把JSON的null转换为空值
+func (data *ColumnData) UnmarshalJSON(bytes []byte) error {
+	var values []*string
+	if err := json.Unmarshal(bytes, &values); err != nil {
+		return err
+	}
+	if values == nil {
+		*data = nil
+		return nil
+	}
+	*data = make(ColumnData, len(values))
+	for i, value := range values {
+		(*data)[i] = NullValue
+		if value != nil {
+			(*data)[i] = *value
+		}
+	}
+	return nil
+}
+
+// 表级约束的存储结构
+type ConstraintJson struct {
+	Name             string         `json:"name"`
+	ConstraintType   ConstraintType `json:"constraint_type"`
+	Fields           []string       `json:"fields"`
+	ForeignKeyTable  string         `json:"foreign_key_table,omitempty"`
+	ForeignKeyColumn string         `json:"foreign_key_column,omitempty"`
+	// Check约束的条件和连接运算符
+	Conditions         []Condition         `json:"conditions,omitempty"`
+	ConditionOperators []ConditionOperator `json:"condition_operators,omitempty"`
 }
 
 type IndexJson struct {
@@ -62,6 +121,15 @@ type TableAndFields struct {
 func Handle(sql Sql) (result []Record, rows int, err error) {
 	switch sql.Type {
 	case CreateTable:
+		// CREATE TABLE ... AS SELECT返回插入的行数
+		if sql.Query != nil {
+			rows, err = handleCreateTableAsSelect(sql)
+			if err != nil {
+				return nil, 0, err
+			} else {
+				return nil, rows, nil
+			}
+		}
 		err = handleCreateTable(sql)
 		if err != nil {
 			return nil, 0, err
@@ -75,6 +143,48 @@ func Handle(sql Sql) (result []Record, rows int, err error) {
 		} else {
 			return nil, 1, err
 		}
+	case CreateMaterializedView:
+		rows, err = handleCreateMaterializedView(sql)
+		if err != nil {
+			return nil, 0, err
+		} else {
+			return nil, rows, nil
+		}
+	case RefreshMaterializedView:
+		rows, err = handleRefreshMaterializedView(sql)
+		if err != nil {
+			return nil, 0, err
+		} else {
+			return nil, rows, nil
+		}
+	case DropTable, DropView, DropMaterializedView:
+		rows, err = handleDrop(sql)
+		if err != nil {
+			return nil, 0, err
+		} else {
+			return nil, rows, nil
+		}
+	case AlterTable:
+		rows, err = handleAlterTable(sql)
+		if err != nil {
+			return nil, 0, err
+		} else {
+			return nil, rows, nil
+		}
+	case DropIndex:
+		rows, err = handleDropIndex(sql)
+		if err != nil {
+			return nil, 0, err
+		} else {
+			return nil, rows, nil
+		}
+	case DropUser:
+		rows, err = handleDropUser(sql)
+		if err != nil {
+			return nil, 0, err
+		} else {
+			return nil, rows, nil
+		}
 	case CreateIndex:
 		count, err := handleCreateIndex(sql)
 		if err != nil {
@@ -138,9 +248,9 @@ func Handle(sql Sql) (result []Record, rows int, err error) {
 
 // 建表的处理器
 func handleCreateTable(sql Sql) (err error) {
-	// 创建表的JSON文件
-	createJsonFile(sql.Tables[0])
-
+	if err = checkObjectName(sql.Tables[0], "CREATE TABLE"); err != nil {
+		return err
+	}
 	// 创建列定义的结构体数组
 	var fields []FieldJson
 	// 把每一个列都转换为一个对象，加入结构体数组
@@ -149,12 +259,16 @@ func handleCreateTable(sql Sql) (err error) {
 			Name:             field.Name,
 			DataType:         field.DataType,
 			DataLength:       field.DataLength,
+			Scale:            field.Scale,
 			NotNull:          field.NotNull,
 			Unique:           field.Unique,
 			PrimaryKey:       field.PrimaryKey,
 			ForeignKey:       field.ForeignKey,
 			ForeignKeyTable:  field.ForeignKeyReferenceTable,
 			ForeignKeyColumn: field.ForeignKeyReferenceField,
+			OnDelete:         field.OnDelete,
+			OnUpdate:         field.OnUpdate,
+			Default:          field.Default,
 			Data:             []string{},
 		})
 	}
@@ -164,6 +278,63 @@ func handleCreateTable(sql Sql) (err error) {
 		Name:   sql.Tables[0],
 		Fields: fields,
 	}
+	// 列级和表级的Check约束都作为表级约束保存
+	for _, field := range sql.CreateFields {
+		if len(field.CheckConditions) > 0 {
+			err = addCheckConstraint(&table, "", field.CheckConditions, field.CheckConditionsOperator, "CREATE TABLE")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, constraint := range sql.TableConstraints {
+		if constraint.ConstraintType == Check {
+			err = addCheckConstraint(&table, constraint.Name, constraint.Conditions, constraint.ConditionOperators, "CREATE TABLE")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// 一个表只能有一个主键
+	var primaryKeys []string
+	for _, field := range fields {
+		if field.PrimaryKey {
+			primaryKeys = append(primaryKeys, field.Name)
+		}
+	}
+	if len(primaryKeys) > 1 {
+		return fmt.Errorf("at CREATE TABLE: multiple primary keys (%s) for table %s, use PRIMARY KEY (%s) for a composite key",
+			strings.Join(primaryKeys, ", "), table.Name, strings.Join(primaryKeys, ", "))
+	}
+	// 单列的主键和唯一约束记录在列中，约束名记录在表级约束中
+	for _, field := range sql.CreateFields {
+		if err = recordFieldKeyConstraints(&table, field, "CREATE TABLE"); err != nil {
+			return err
+		}
+	}
+	// 多列的主键和唯一约束作为表级约束保存
+	for _, constraint := range sql.TableConstraints {
+		if constraint.ConstraintType == PrimaryKey || constraint.ConstraintType == Unique {
+			err = addKeyConstraint(&table, constraint.Name, constraint.ConstraintType, constraint.Fields, "CREATE TABLE")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// 默认值必须能计算出符合列的数据类型的值
+	for _, field := range fields {
+		if _, err = defaultValue(field); err != nil {
+			return fmt.Errorf("at CREATE TABLE: %v", err)
+		}
+	}
+	// 外键参照的表和列必须存在
+	if err = checkCreateForeignKeys(&table); err != nil {
+		return err
+	}
+
+	// 约束都合法之后再创建表的JSON文件
+	createJsonFile(sql.Tables[0])
 
 	tableJson, err := json.Marshal(table)
 	if err != nil {
@@ -179,20 +350,76 @@ func handleCreateTable(sql Sql) (err error) {
 	return nil
 }
 
+// 用查询结果建表的处理器，列的定义由查询结果中的列得到，返回插入的行数
+func handleCreateTableAsSelect(sql Sql) (rows int, err error) {
+	tableName := sql.Tables[0]
+	if err = checkObjectName(tableName, "CREATE TABLE"); err != nil {
+		return 0, err
+	}
+	objectType, err := getObjectType(tableName)
+	if err != nil {
+		return 0, err
+	}
+	if objectType != Unknown {
+		return 0, fmt.Errorf("at CREATE TABLE: %s %s already exists", objectTypeString(objectType), tableName)
+	}
+	set, err := selectResultSet(*sql.Query, nil)
+	if err != nil {
+		return 0, err
+	}
+	table, err := set.table(tableName)
+	if err != nil {
+		return 0, fmt.Errorf("at CREATE TABLE: %v", err)
+	}
+	createJsonFile(tableName)
+	if err = writeTableJson(table); err != nil {
+		panic(err)
+	}
+	return tableRowCount(table), nil
+}
+
 // 创建视图的处理器
 func handleCreateView(sql Sql) (err error) {
+	viewName := sql.Tables[0]
+	// 视图不能和已有的表同名
+	if tableName, err := getObjectFileByName(viewName + ".json"); err == nil && tableName != "" {
+		return fmt.Errorf("at CREATE VIEW: table %s already exists", viewName)
+	}
+	query, err := parseSelect(sql.ViewSelect)
+	if err != nil {
+		return fmt.Errorf("at CREATE VIEW: %v", err)
+	}
+	// 视图不能直接或者间接地引用自己
+	found, err := viewReferences(query, viewName, map[string]bool{})
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("at CREATE VIEW: view %s cannot reference itself", viewName)
+	}
+	// 执行一次视图的SELECT语句，检查引用的表和列是否存在，以及列名的个数是否正确
+	if _, err := viewResultSet(&sql); err != nil {
+		return err
+	}
+	// 只有可更新视图才能使用WITH CHECK OPTION
+	if sql.WithCheckOption {
+		if _, err := newUpdatableView(&sql); err != nil {
+			return fmt.Errorf("at CREATE VIEW: WITH CHECK OPTION requires an updatable view: %v", err)
+		}
+	}
+
 	// 用视图名新建文件
-	createTxtFile(sql.Tables[0])
+	createTxtFile(viewName)
 
 	// 打开文件名称对应的txt文件
-	file, err := os.OpenFile("./file/"+sql.Tables[0]+".txt", os.O_APPEND|os.O_WRONLY, os.ModeAppend)
+	file, err := os.OpenFile("./file/"+viewName+".txt", os.O_APPEND|os.O_WRONLY, os.ModeAppend)
 	if err != nil {
 		panic(err)
 	}
 	defer file.Close()
 
 	writer := bufio.NewWriter(file)
-	_, err = writer.WriteString(sql.ViewSelect)
+	_, err = writer.WriteString(viewDefinition(sql))
 	if err != nil {
 		panic(err)
 	}
@@ -204,6 +431,57 @@ func handleCreateView(sql Sql) (err error) {
 	return nil
 }
 
+// 创建物化视图的处理器，返回物化视图中的行数
+func handleCreateMaterializedView(sql Sql) (rows int, err error) {
+	viewName := sql.Tables[0]
+	if err = checkObjectName(viewName, "CREATE MATERIALIZED VIEW"); err != nil {
+		return 0, err
+	}
+	// 物化视图和表存储在同名的JSON文件中，不能和已有的表或者视图同名
+	if fileName, err := getObjectFileByName(viewName + ".json"); err != nil || fileName != "" {
+		if err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("at CREATE MATERIALIZED VIEW: table %s already exists", viewName)
+	}
+	if fileName, err := getObjectFileByName(viewName + ".txt"); err == nil && fileName != "" {
+		return 0, fmt.Errorf("at CREATE MATERIALIZED VIEW: view %s already exists", viewName)
+	}
+	table, err := materializeView(sql)
+	if err != nil {
+		return 0, err
+	}
+	createJsonFile(viewName)
+	if err = writeTableJson(table); err != nil {
+		panic(err)
+	}
+	return tableRowCount(table), nil
+}
+
+// 重新计算物化视图的处理器，返回物化视图中的行数
+func handleRefreshMaterializedView(sql Sql) (rows int, err error) {
+	viewName := sql.Tables[0]
+	table, err := readTableJson(viewName)
+	if err != nil {
+		return 0, fmt.Errorf("at REFRESH MATERIALIZED VIEW: %v", err)
+	}
+	if table.Definition == "" {
+		return 0, fmt.Errorf("at REFRESH MATERIALIZED VIEW: %s is not a materialized view", viewName)
+	}
+	definition, err := parse(table.Definition)
+	if err != nil {
+		return 0, fmt.Errorf("at materialized view %s: %v", viewName, err)
+	}
+	table, err = materializeView(definition)
+	if err != nil {
+		return 0, err
+	}
+	if err = writeTableJson(table); err != nil {
+		panic(err)
+	}
+	return tableRowCount(table), nil
+}
+
 // 创建索引的处理器
 func handleCreateIndex(sql Sql) (indexCount int, err error) {
 	// 每个列一个JSON文件
@@ -221,7 +499,24 @@ func handleCreateIndex(sql Sql) (indexCount int, err error) {
 
 // 处理INSERT插入语句
 func handleInsert(sql Sql) (rows int, err error) {
-	fileName, err := getFileByName(sql.Tables[0] + ".json")
+	// 省略列名时按照表或者视图中列的顺序插入
+	if len(sql.Fields) == 0 {
+		sql.Fields, err = insertColumns(sql.Tables[0])
+		if err != nil {
+			return 0, err
+		}
+	}
+	// INSERT INTO ... SELECT：先执行查询，查询结果作为要插入的数据
+	sql, err = insertSelectRows(sql)
+	if err != nil {
+		return 0, err
+	}
+	// 对可更新视图的插入改写为对基本表的插入
+	sql, view, err := rewriteViewStatement(sql)
+	if err != nil {
+		return 0, err
+	}
+	fileName, err := getObjectFileByName(sql.Tables[0] + ".json")
 	path := "./file/"
 	if err != nil {
 		panic(err)
@@ -241,7 +536,28 @@ func handleInsert(sql Sql) (rows int, err error) {
 	if err != nil {
 		panic(err)
 	}
+	// 把插入的每一行补全为表中所有的列
+	sql, err = completeInsertRows(table, sql)
+	if err != nil {
+		return 0, err
+	}
+	// 通过带有WITH CHECK OPTION的视图插入时，插入的行必须满足视图的条件，并且插入的行都必须满足表中的Check约束
+	for _, insertValue := range sql.Inserts {
+		if err := view.checkRow("INSERT", insertRowGetter(table, sql.Fields, insertValue)); err != nil {
+			return 0, err
+		}
+		if err := checkConstraints(table, "INSERT", insertRowGetter(table, sql.Fields, insertValue)); err != nil {
+			return 0, err
+		}
+	}
 	// 处理插入请求
+	rowCount := tableRowCount(table)
+	// 有的列的数据比其他列少，先用空值补齐，保证插入的数据在每一列中都是同一行
+	for index := range table.Fields {
+		for len(table.Fields[index].Data) < rowCount {
+			table.Fields[index].Data = append(table.Fields[index].Data, NullValue)
+		}
+	}
 	// 找到对应列名的数据，插入到对应的列中
 	for index, insertFieldName := range sql.Fields {
 		// 是否找到对应的列
@@ -271,6 +587,18 @@ func handleInsert(sql Sql) (rows int, err error) {
 		}
 		flag = false
 	}
+	var insertedRows []int
+	for row := rowCount; row < tableRowCount(table); row++ {
+		insertedRows = append(insertedRows, row)
+	}
+	// 插入的行必须满足多列的主键和唯一约束，主键的列不能为空
+	if err = checkKeyConstraints(table, insertedRows, "INSERT"); err != nil {
+		return 0, err
+	}
+	// 插入的外键的值必须在被参照列中出现
+	if err = newTableChanges("INSERT", table).checkReferences(table, insertedRows, sql.Fields); err != nil {
+		return 0, err
+	}
 	// 开始覆盖写入文件
 	jsonTable, err := json.Marshal(table)
 	if err != nil {
@@ -283,12 +611,128 @@ func handleInsert(sql Sql) (rows int, err error) {
 	return len(sql.Inserts), nil
 }
 
+// 执行INSERT INTO ... SELECT中的查询，把查询结果放入要插入的数据中
+func insertSelectRows(sql Sql) (rewritten Sql, err error) {
+	if sql.Query == nil {
+		return sql, nil
+	}
+	set, err := selectResultSet(*sql.Query, nil)
+	if err != nil {
+		return sql, err
+	}
+	if len(set.fields) != len(sql.Fields) {
+		return sql, fmt.Errorf("at INSERT INTO: SELECT returns %d columns but %d fields are given", len(set.fields), len(sql.Fields))
+	}
+	sql.Inserts = [][]string{}
+	for _, row := range set.rows {
+		sql.Inserts = append(sql.Inserts, append([]string{}, row...))
+	}
+	return sql, nil
+}
+
+// 表或者可更新视图中全部的列名，用于省略了列名的INSERT语句
+func insertColumns(tableName string) (fields []string, err error) {
+	table, err := readTableJson(tableName)
+	if err == nil {
+		for _, field := range table.Fields {
+			fields = append(fields, field.Name)
+		}
+		return fields, nil
+	}
+	view, viewErr := readUpdatableView(tableName)
+	if viewErr != nil {
+		return nil, fmt.Errorf("at INSERT: %v", viewErr)
+	}
+	if view == nil {
+		return nil, fmt.Errorf("at INSERT: %v", err)
+	}
+	return view.Columns, nil
+}
+
+// 把要插入的每一行补全为表中所有的列，按照表中列的顺序排列，并按照列的数据类型检查和规范化每一个值
+// 没有给出的列使用列的默认值，没有默认值时为空值
+func completeInsertRows(table *TableJson, sql Sql) (completed Sql, err error) {
+	for index, fieldName := range sql.Fields {
+		if tableFieldIndex(table, fieldName) < 0 {
+			return sql, fmt.Errorf("at INSERT: unknown field %s in table %s", fieldName, table.Name)
+		}
+		if containsString(sql.Fields[:index], fieldName) {
+			return sql, fmt.Errorf("at INSERT: field %s is given more than once", fieldName)
+		}
+	}
+	var rows [][]string
+	for _, values := range sql.Inserts {
+		if len(values) != len(sql.Fields) {
+			return sql, fmt.Errorf("at INSERT: %d values are given but %d fields are expected", len(values), len(sql.Fields))
+		}
+		var row []string
+		for _, field := range table.Fields {
+			value, given := NullValue, false
+			for index, fieldName := range sql.Fields {
+				if fieldName == field.Name {
+					value, given = values[index], true
+				}
+			}
+			if !given {
+				if value, err = defaultValue(field); err != nil {
+					return sql, fmt.Errorf("at INSERT: %v", err)
+				}
+			}
+			// 插入的值必须符合列的数据类型
+			if value, err = convertFieldValue(field, value, "INSERT"); err != nil {
+				return sql, err
+			}
+			row = append(row, value)
+		}
+		rows = append(rows, row)
+	}
+	sql.Fields = []string{}
+	for _, field := range table.Fields {
+		sql.Fields = append(sql.Fields, field.Name)
+	}
+	sql.Inserts = rows
+	return sql, nil
+}
+
+// 计算列的默认值，没有默认值时为空值
+// 默认值的表达式不能引用列，每次插入时重新计算，例如DEFAULT NOW()
+func defaultValue(field FieldJson) (value string, err error) {
+	if field.Default == "" {
+		return NullValue, nil
+	}
+	p := &parser{sql: field.Default}
+	expression, _, err := p.parseExpression()
+	if err == nil && p.peek() != "" {
+		err = fmt.Errorf("unexpected %s", p.peek())
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid DEFAULT of field %s: %v", field.Name, err)
+	}
+	if len(expression.fields(true)) > 0 {
+		return "", fmt.Errorf("DEFAULT of field %s cannot reference columns", field.Name)
+	}
+	value, _, err = expression.evaluate(func(fieldName string) (string, DataType, error) {
+		return "", UnknownDataType, fmt.Errorf("unknown field %s", fieldName)
+	})
+	if err == nil {
+		value, err = convertValue(value, field.DataType, field.DataLength, field.Scale)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid DEFAULT of field %s: %v", field.Name, err)
+	}
+	return value, nil
+}
+
 // 检查唯一
 func checkUnique(value string, field FieldJson) (result bool) {
 	// 该列没有定义唯一约束，就不需要检查
 	if field.PrimaryKey == false && field.Unique == false {
 		return true
 	}
+	// 空值和任何值都不重复
+	if value == NullValue {
+		return true
+	}
 	for _, data := range field.Data {
 		// 查找到重复的值了，检查不通过，返回false
 		if value == data {
@@ -304,75 +748,104 @@ func checkNotNull(value string, field FieldJson) (result bool) {
 	if field.PrimaryKey == false && field.NotNull == false {
 		return true
 	}
-	if value == "" {
+	if value == NullValue {
 		return false
 	} else {
 		return true
 	}
 }
 
+// 处理SELECT查询语句
 func handleSelect(sql Sql) (result []Record, err error) {
-	fileName, err := getFileByName(sql.Tables[0] + ".json")
-	path := "./file/"
+	set, err := selectResultSet(sql, nil)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	// 不存在这个名称的表文件，说明该表不存在
-	if fileName == "" {
-		return nil, fmt.Errorf("at SELECT: unknown table name %s", sql.Tables[0])
+	return set.records(), nil
+}
+
+// 执行SELECT语句，得到查询结果
+// outer是外层查询当前行的valueGetter，执行相关子查询时使用，不是子查询时为nil
+func selectResultSet(sql Sql, outer valueGetter) (set *resultSet, err error) {
+	// UNION、INTERSECT、EXCEPT
+	if len(sql.SetOperations) > 0 {
+		return setOperationResultSet(sql, outer)
 	}
-	// 读表文件内容
-	bytes, err := ioutil.ReadFile(path + fileName)
+	// 读取FROM子句中的表，连接后用Where子句筛选
+	set, err = selectFromTables(sql, outer)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	// 把表文件转换为结构体
-	table := &TableJson{}
-	err = json.Unmarshal(bytes, table)
+	// 处理GROUP BY子句、聚集函数和HAVING子句
+	set, err = groupResultSet(set, sql)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	// 处理查询请求
-	result = []Record{}
-	for _, selectField := range sql.Fields {
-		flag := false
-		for _, field := range table.Fields {
-			if selectField == field.Name {
-				result = append(result, Record{
-					Field: Field{
-						Name:                     field.Name,
-						DataType:                 field.DataType,
-						DataLength:               field.DataLength,
-						Constraint:               nil,
-						CheckConditions:          nil,
-						CheckConditionsOperator:  nil,
-						PrimaryKey:               field.PrimaryKey,
-						NotNull:                  field.NotNull,
-						Unique:                   field.Unique,
-						ForeignKey:               field.ForeignKey,
-						ForeignKeyFlag:           false,
-						ForeignKeyReferenceTable: field.ForeignKeyTable,
-						ForeignKeyReferenceField: field.ForeignKeyColumn,
-					},
-					Data: field.Data,
-				})
-				flag = true
+	// 处理ORDER BY子句，排序的列可以是查询的列的别名或者位置
+	orderBy, err := resolveAliases(sql.OrderByExpressions, sql)
+	if err != nil {
+		return nil, err
+	}
+	err = set.sort(orderBy, sql.OrderByArrangement)
+	if err != nil {
+		return nil, err
+	}
+	// 按照查询的列进行投影
+	set, err = set.project(sql.FieldExpressions, sql.Fields, sql.Aliases)
+	if err != nil {
+		return nil, err
+	}
+	// 处理DISTINCT，按整行去掉重复的行，保留排序后第一次出现的行
+	if sql.Distinct {
+		set.distinct()
+	}
+	// 处理LIMIT、OFFSET子句
+	set.paginate(sql.Offset, sql.Limit)
+	return set, nil
+}
+
+// 把排序的表达式中查询的列的别名替换为该列的表达式
+func resolveAliases(expressions []*Expression, sql Sql) (result []*Expression, err error) {
+	for _, expression := range expressions {
+		// 整数n表示查询的第n列
+		if position, ok := orderByPosition(expression); ok {
+			if position < 1 || position > len(sql.FieldExpressions) {
+				return nil, fmt.Errorf("at ORDER BY: position %d is not in select list", position)
+			}
+			expression = sql.FieldExpressions[position-1]
+			if expression.Type == FieldExpression && strings.HasSuffix(expression.Value, "*") {
+				return nil, fmt.Errorf("at ORDER BY: position %d refers to %s", position, expression.Value)
+			}
+		} else if expression.Type == FieldExpression {
+			for index, alias := range sql.Aliases {
+				if alias != "" && alias == expression.Value {
+					expression = sql.FieldExpressions[index]
+					break
+				}
 			}
 		}
-		if flag != true {
-			return nil, fmt.Errorf("at INSERT: unknown field %s in table %s", selectField, table.Name)
-		}
-		flag = false
+		result = append(result, expression)
 	}
-
 	return result, nil
 }
 
-// TODO 处理Where子句
+// ORDER BY中不带引号的整数是查询的列的位置，从1开始
+func orderByPosition(expression *Expression) (position int, ok bool) {
+	if expression.Type != LiteralExpression || expression.Quoted {
+		return 0, false
+	}
+	position, err := strconv.Atoi(expression.Value)
+	return position, err == nil
+}
 
 // 处理UPDATE更新语句
 func handleUpdate(sql Sql) (rows int, err error) {
-	fileName, err := getFileByName(sql.Tables[0] + ".json")
+	// 对可更新视图的更新改写为对基本表的更新
+	sql, view, err := rewriteViewStatement(sql)
+	if err != nil {
+		return 0, err
+	}
+	fileName, err := getObjectFileByName(sql.Tables[0] + ".json")
 	path := "./file/"
 	if err != nil {
 		panic(err)
@@ -392,41 +865,78 @@ func handleUpdate(sql Sql) (rows int, err error) {
 	if err != nil {
 		panic(err)
 	}
-	rows = 0
-	// 处理更新请求
-	for fieldName, value := range sql.Updates {
-		flag := false
-		for fieldIndex, field := range table.Fields {
-			if field.Name == fieldName {
-				var updateData []string
-				for range table.Fields[fieldIndex].Data {
-					updateData = append(updateData, value)
-				}
-				table.Fields[fieldIndex].Data = updateData
-				flag = true
-				rows += len(updateData)
+	// 筛选出满足Where子句的行，只更新这些行
+	matchedRows, err := filterTableRows(table, sql.Conditions, sql.ConditionOperators)
+	if err != nil {
+		return 0, err
+	}
+	// 先用更新前的数据计算出所有的新值，再统一写入，例如SET Sage = Sage + 1
+	newValues := map[string][]string{}
+	for fieldName, expression := range sql.Updates {
+		if _, err := expression.dataType(tableSchema(table)); err != nil {
+			return 0, fmt.Errorf("at UPDATE: %v", err)
+		}
+		newValues[fieldName] = []string{}
+		for _, row := range matchedRows {
+			value, _, err := expression.evaluate(tableRowGetter(table, row))
+			if err != nil {
+				return 0, fmt.Errorf("at UPDATE: %v", err)
 			}
+			newValues[fieldName] = append(newValues[fieldName], value)
 		}
-		if flag != true {
+	}
+	// 处理更新请求，被更新的列被外键参照时，按照外键的ON UPDATE动作处理参照旧值的行
+	changes := newTableChanges("UPDATE", table)
+	var updatedFields []string
+	for fieldName, values := range newValues {
+		fieldIndex := tableFieldIndex(table, fieldName)
+		if fieldIndex < 0 {
 			return 0, fmt.Errorf("at UPDATE: unknown field %s in table %s", fieldName, table.Name)
 		}
-		flag = false
+		// 新的值必须符合列的数据类型
+		for index, value := range values {
+			if !checkNotNull(value, table.Fields[fieldIndex]) {
+				return 0, fmt.Errorf("at UPDATE: attempt to set a NOT NULL field %s to null", fieldName)
+			}
+			if values[index], err = convertFieldValue(table.Fields[fieldIndex], value, "UPDATE"); err != nil {
+				return 0, err
+			}
+		}
+		if err = changes.updateValues(table, fieldIndex, matchedRows, values); err != nil {
+			return 0, err
+		}
+		updatedFields = append(updatedFields, fieldName)
 	}
-	// 开始覆盖写入文件
-	jsonTable, err := json.Marshal(table)
-	if err != nil {
-		panic(err)
+	// 通过带有WITH CHECK OPTION的视图更新时，更新后的行必须仍然满足视图的条件，并且都必须满足表中的Check约束
+	for _, row := range matchedRows {
+		if err := view.checkRow("UPDATE", tableRowGetter(table, row)); err != nil {
+			return 0, err
+		}
+		if err := checkConstraints(table, "UPDATE", tableRowGetter(table, row)); err != nil {
+			return 0, err
+		}
 	}
-	err = ioutil.WriteFile(path+fileName, jsonTable, os.ModeAppend)
-	if err != nil {
-		panic(err)
+	// 更新后的行必须满足主键和唯一约束
+	if err = checkKeyConstraints(table, matchedRows, "UPDATE"); err != nil {
+		return 0, err
+	}
+	// 更新后的外键的值必须在被参照列中出现
+	if err = changes.checkReferences(table, matchedRows, updatedFields); err != nil {
+		return 0, err
 	}
-	return rows, nil
+	// 开始覆盖写入被修改的所有表
+	changes.write()
+	return len(matchedRows), nil
 }
 
 // 处理删除
 func handleDelete(sql Sql) (rows int, err error) {
-	fileName, err := getFileByName(sql.Tables[0] + ".json")
+	// 对可更新视图的删除改写为对基本表的删除
+	sql, _, err = rewriteViewStatement(sql)
+	if err != nil {
+		return 0, err
+	}
+	fileName, err := getObjectFileByName(sql.Tables[0] + ".json")
 	path := "./file/"
 	if err != nil {
 		panic(err)
@@ -446,26 +956,19 @@ func handleDelete(sql Sql) (rows int, err error) {
 	if err != nil {
 		panic(err)
 	}
-	rows = 0
-	// 处理删除请求
-	for index, field := range table.Fields {
-		if len(field.Data) > rows {
-			rows = len(field.Data)
-		}
-		// 删除数据
-		table.Fields[index].Data = field.Data[0:0]
-		continue
-	}
-	// 开始覆盖写入文件
-	jsonTable, err := json.Marshal(table)
+	// 筛选出满足Where子句的行，只删除这些行
+	matchedRows, err := filterTableRows(table, sql.Conditions, sql.ConditionOperators)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
-	err = ioutil.WriteFile(path+fileName, jsonTable, os.ModeAppend)
-	if err != nil {
-		panic(err)
+	// 处理删除请求，被删除的行被外键参照时，按照外键的ON DELETE动作处理参照它们的行
+	changes := newTableChanges("DELETE", table)
+	if err = changes.deleteRows(table, matchedRows); err != nil {
+		return 0, err
 	}
-	return rows, nil
+	// 开始覆盖写入被修改的所有表
+	changes.write()
+	return len(matchedRows), nil
 }
 
 func handleCreateUser(sql Sql) (err error) {
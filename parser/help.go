@@ -48,7 +48,7 @@ func HandleHelp(help string) (err error) {
 
 // help database命令的处理器
 func handleHelpDataBase() (err error) {
-	tables, indexes, views, err := getFilesForHelpDataBase()
+	tables, indexes, views, materializedViews, err := getFilesForHelpDataBase()
 	if err != nil {
 		return err
 	}
@@ -64,6 +64,12 @@ func handleHelpDataBase() (err error) {
 		fmt.Print("- ")
 		fmt.Println(strings.TrimSuffix(view, ".txt"))
 	}
+	// 物化视图
+	fmt.Println("Materialized Views: ")
+	for _, view := range materializedViews {
+		fmt.Print("- ")
+		fmt.Println(strings.TrimSuffix(view, ".json"))
+	}
 	// 索引
 	fmt.Println("Indexes: ")
 	for _, index := range indexes {
@@ -76,7 +82,7 @@ func handleHelpDataBase() (err error) {
 // help table命令的处理器
 func handleHelpTable(help string) (err error) {
 	s := strings.Split(help, " ")
-	fileName, err := getFileByName(s[2] + ".json")
+	fileName, err := getObjectFileByName(s[2] + ".json")
 	path := "./file/"
 	if err != nil {
 		return err
@@ -100,9 +106,22 @@ func handleHelpTable(help string) (err error) {
 	// 处理帮助命令
 	for _, field := range table.Fields {
 		fmt.Printf("%-10s\t|%-10s\t|%-10d\t|%-10s\t|%-10s\t|%-10s\t|%-10s\t|%-10s\t|%-10s\t\n",
-			field.Name, DataTypeString[field.DataType], field.DataLength, strconv.FormatBool(field.NotNull), strconv.FormatBool(field.Unique),
+			field.Name, dataTypeName(field.DataType, field.DataLength, field.Scale), field.DataLength, strconv.FormatBool(field.NotNull), strconv.FormatBool(field.Unique),
 			strconv.FormatBool(field.PrimaryKey), strconv.FormatBool(field.ForeignKey), field.ForeignKeyTable, field.ForeignKeyColumn)
 	}
+	// 表级约束：多列的主键和唯一约束，以及有名称的约束
+	if len(table.Constraints) > 0 {
+		fmt.Println()
+		fmt.Println("ConstraintName\t|ConstraintType\t|Fields\t|Reference\t")
+		for _, constraint := range table.Constraints {
+			reference := ""
+			if constraint.ConstraintType == ForeignKey {
+				reference = constraint.ForeignKeyTable + "(" + constraint.ForeignKeyColumn + ")"
+			}
+			fmt.Printf("%-10s\t|%-10s\t|%-10s\t|%-10s\t\n", constraint.Name, ConstraintTypeString[constraint.ConstraintType],
+				strings.Join(constraint.Fields, ", "), reference)
+		}
+	}
 	fmt.Println()
 	return nil
 }
@@ -110,14 +129,19 @@ func handleHelpTable(help string) (err error) {
 // help view命令的处理器
 func handleHelpView(help string) (err error) {
 	s := strings.Split(help, " ")
-	fileName, err := getFileByName(s[2] + ".txt")
+	fileName, err := getObjectFileByName(s[2] + ".txt")
 	path := "./file/"
 	if err != nil {
 		return err
 	}
-	// 不存在这个名称的表文件，说明该视图不存在
+	// 不存在这个名称的视图文件时，查找同名的物化视图
 	if fileName == "" {
-		return fmt.Errorf("at HELP: unknown view name %s", s[2])
+		table, err := readTableJson(s[2])
+		if err != nil || table.Definition == "" {
+			return fmt.Errorf("at HELP: unknown view name %s", s[2])
+		}
+		fmt.Println(table.Definition)
+		return nil
 	}
 	// 读文件内容
 	bytes, err := ioutil.ReadFile(path + fileName)
@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 主键或者唯一约束的默认名称：主键为表名_pkey，唯一约束为表名_列名_key
+func keyConstraintName(tableName string, constraintType ConstraintType, fields []string) string {
+	if constraintType == PrimaryKey {
+		return tableName + "_pkey"
+	}
+	return tableName + "_" + strings.Join(fields, "_") + "_key"
+}
+
+// 表中所有的主键和唯一约束：单列的约束记录在列的定义中，多列的约束保存在表级约束中
+// 单列的约束在表级约束中记录了约束名，没有记录时使用默认名称
+func keyConstraints(table *TableJson) (constraints []ConstraintJson) {
+	for _, field := range table.Fields {
+		if field.PrimaryKey {
+			constraints = append(constraints, ConstraintJson{
+				Name:           fieldKeyConstraintName(table, PrimaryKey, field.Name),
+				ConstraintType: PrimaryKey,
+				Fields:         []string{field.Name},
+			})
+		} else if field.Unique {
+			constraints = append(constraints, ConstraintJson{
+				Name:           fieldKeyConstraintName(table, Unique, field.Name),
+				ConstraintType: Unique,
+				Fields:         []string{field.Name},
+			})
+		}
+	}
+	for _, constraint := range table.Constraints {
+		if (constraint.ConstraintType == PrimaryKey || constraint.ConstraintType == Unique) && len(constraint.Fields) > 1 {
+			constraints = append(constraints, constraint)
+		}
+	}
+	return constraints
+}
+
+// 单列的主键或者唯一约束的约束名
+func fieldKeyConstraintName(table *TableJson, constraintType ConstraintType, fieldName string) string {
+	for _, constraint := range table.Constraints {
+		if constraint.ConstraintType == constraintType && len(constraint.Fields) == 1 && constraint.Fields[0] == fieldName {
+			return constraint.Name
+		}
+	}
+	return keyConstraintName(table.Name, constraintType, []string{fieldName})
+}
+
+// 在表级约束中用默认名称记录列级的主键和唯一约束，使它们可以按名称删除，约束本身仍然记录在列的定义中
+func recordFieldKeyConstraints(table *TableJson, field Field, operation string) error {
+	for _, constraintType := range []ConstraintType{PrimaryKey, Unique} {
+		if (constraintType == PrimaryKey && !field.PrimaryKey) || (constraintType == Unique && !field.Unique) {
+			continue
+		}
+		name := keyConstraintName(table.Name, constraintType, []string{field.Name})
+		for _, existing := range table.Constraints {
+			if existing.Name == name {
+				return fmt.Errorf("at %s: constraint %s already exists in table %s", operation, name, table.Name)
+			}
+		}
+		table.Constraints = append(table.Constraints, ConstraintJson{
+			Name:           name,
+			ConstraintType: constraintType,
+			Fields:         []string{field.Name},
+		})
+	}
+	return nil
+}
+
+// 表的主键，包括单列的主键和多列的主键，没有主键时返回nil
+func primaryKeyFields(table *TableJson) []string {
+	for _, constraint := range keyConstraints(table) {
+		if constraint.ConstraintType == PrimaryKey {
+			return constraint.Fields
+		}
+	}
+	return nil
+}
+
+// 判断列是否单独构成主键或者唯一约束，只有这样的列才能被外键参照，被参照的每个值都只对应一行
+func isKeyField(table *TableJson, fieldName string) bool {
+	for _, constraint := range keyConstraints(table) {
+		if len(constraint.Fields) == 1 && constraint.Fields[0] == fieldName {
+			return true
+		}
+	}
+	return false
+}
+
+// 把多列的主键或者唯一约束加入表定义，表中已有的数据必须满足这个约束
+func addKeyConstraint(table *TableJson, name string, constraintType ConstraintType, fields []string, operation string) error {
+	for _, fieldName := range fields {
+		if tableFieldIndex(table, fieldName) < 0 {
+			return fmt.Errorf("at %s: unknown field %s in table %s", operation, fieldName, table.Name)
+		}
+	}
+	if constraintType == PrimaryKey && primaryKeyFields(table) != nil {
+		return fmt.Errorf("at %s: table %s already has a primary key (%s)", operation, table.Name, strings.Join(primaryKeyFields(table), ", "))
+	}
+	if name == "" {
+		name = keyConstraintName(table.Name, constraintType, fields)
+	}
+	constraint := ConstraintJson{
+		Name:           name,
+		ConstraintType: constraintType,
+		Fields:         fields,
+	}
+	var rows []int
+	for row := 0; row < tableRowCount(table); row++ {
+		rows = append(rows, row)
+	}
+	if err := checkKeyConstraint(table, constraint, rows, operation); err != nil {
+		return err
+	}
+	table.Constraints = append(table.Constraints, constraint)
+	return nil
+}
+
+// 检查插入或者更新后的一些行是否满足表中所有的主键和唯一约束
+func checkKeyConstraints(table *TableJson, rows []int, operation string) error {
+	for _, constraint := range keyConstraints(table) {
+		if err := checkKeyConstraint(table, constraint, rows, operation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 检查一些行是否满足主键或者唯一约束：约束中各列的值组成的元组不能和其他行重复
+// 主键中的列不能为空值，唯一约束中有空值的元组不参与比较
+func checkKeyConstraint(table *TableJson, constraint ConstraintJson, rows []int, operation string) error {
+	getTuple := func(row int) (tuple []string, hasNull bool) {
+		getValue := tableRowGetter(table, row)
+		for _, fieldName := range constraint.Fields {
+			value, _, _ := getValue(fieldName)
+			if value == NullValue {
+				hasNull = true
+			}
+			tuple = append(tuple, value)
+		}
+		return tuple, hasNull
+	}
+	// 每个元组出现的次数
+	counts := map[string]int{}
+	for row := 0; row < tableRowCount(table); row++ {
+		tuple, hasNull := getTuple(row)
+		if !hasNull {
+			counts[joinValues(tuple)]++
+		}
+	}
+	for _, row := range rows {
+		tuple, hasNull := getTuple(row)
+		if hasNull {
+			if constraint.ConstraintType == PrimaryKey {
+				return fmt.Errorf("at %s: PRIMARY KEY (%s) of table %s cannot contain null values",
+					operation, strings.Join(constraint.Fields, ", "), table.Name)
+			}
+			continue
+		}
+		if counts[joinValues(tuple)] > 1 {
+			return fmt.Errorf("at %s: value (%s) breaks %s constraint %s on fields (%s)", operation, strings.Join(tuple, ", "),
+				ConstraintTypeString[constraint.ConstraintType], constraint.Name, strings.Join(constraint.Fields, ", "))
+		}
+	}
+	return nil
+}
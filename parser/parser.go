@@ -7,40 +7,166 @@ import (
 	"strings"
 )
 
+// 创建视图的语句末尾的WITH CHECK OPTION
+var withCheckOptionRegexp = regexp.MustCompile(`(?i)\s+WITH\s+CHECK\s+OPTION\s*;?\s*$`)
+
 // 解析完成的SQL
 type Sql struct {
-	Type               Type                // 该条SQL语句的类型
-	Tables             []string            // 该条SQL语句操作的表名，因为要实现多表查询所以可能有多个
-	Conditions         []Condition         // 查询条件：Where语句后的部分
-	Updates            map[string]string   // 更新数据的Map
-	Inserts            [][]string          // 插入的数据，如果不是Insert类型则为nil
-	Fields             []string            // 受影响的列
-	CreateFields       []Field             // 新建的列，如果不是CreateTable类型则为nil
-	ConditionOperators []ConditionOperator // Where字句之间的连接符
-	ViewSelect         string              // 创建视图时使用，为该视图定义的Select语句
-	IndexName          string              // 创建索引时使用，为创建的索引名称
-	IndexType          string              // 建立的索引的类型
-	IndexArrangement   []string            // 索引的排列方向：ASC或者DESC
-	Username           string              // 创建的用户的用户名/授权时的用户名
-	Password           string              // 创建的用户的密码
-	Privileges         []Privilege         // 赋予或收回用户的权限
-	Users              []string            // 被操作权限的用户
+	Type               Type                   // 该条SQL语句的类型
+	Tables             []string               // 该条SQL语句操作的表名，因为要实现多表查询所以可能有多个
+	Joins              []Join                 // FROM子句中用JOIN连接的表，在Tables中的表连接完成之后依次连接
+	DerivedTables      map[string]*Sql        // FROM子句中的派生表：表的别名到子查询，例如FROM (SELECT ...) AS t
+	TableAliases       map[string]string      // FROM子句中表的别名：别名到表名，例如FROM SC AS x
+	Conditions         []Condition            // 查询条件：Where语句后的部分
+	Updates            map[string]*Expression // 更新数据的Map：列名到新值的表达式
+	Inserts            [][]string             // 插入的数据，如果不是Insert类型则为nil
+	Fields             []string               // 受影响的列，查询时为查询的列的原文
+	FieldExpressions   []*Expression          // 查询的列的表达式，与Fields一一对应
+	Aliases            []string               // 查询的列的别名，与Fields一一对应，没有别名的列为空字符串
+	Distinct           bool                   // SELECT DISTINCT：去掉查询结果中重复的行
+	GroupByFields      []string               // GROUP BY子句中用于分组的列
+	HavingConditions   []Condition            // HAVING子句中的条件
+	HavingOperators    []ConditionOperator    // HAVING子句中条件之间的连接符
+	CreateFields       []Field                // 新建的列，如果不是CreateTable类型则为nil
+	ConditionOperators []ConditionOperator    // Where字句之间的连接符
+	ViewSelect         string                 // 创建视图时使用，为该视图定义的Select语句
+	WithCheckOption    bool                   // 创建视图时使用，通过视图插入和更新的行必须满足视图的条件
+	IndexName          string                 // 创建索引时使用，为创建的索引名称
+	IndexType          string                 // 建立的索引的类型
+	IndexArrangement   []string               // 索引的排列方向：ASC或者DESC
+	OrderByFields      []string               // ORDER BY子句中用于排序的列，排在前面的优先
+	OrderByArrangement []string               // 排序方向：ASC或者DESC，与OrderByFields一一对应
+	OrderByExpressions []*Expression          // 用于排序的表达式，与OrderByFields一一对应
+	Limit              int                    // LIMIT / FETCH FIRST子句限制的最多返回的行数，为-1时不限制
+	Offset             int                    // OFFSET子句指定的跳过的行数
+	SetOperations      []SetOperation         // 与该SELECT语句做集合运算的其他SELECT语句，ORDER BY和分页作用于集合运算的结果
+	Username           string                 // 创建的用户的用户名/授权时的用户名
+	Password           string                 // 创建的用户的密码
+	Privileges         []Privilege            // 赋予或收回用户的权限
+	Users              []string               // 被操作权限的用户/删除的用户
+	IfExists           bool                   // DROP ... IF EXISTS：要删除的对象不存在时不报错
+	Cascade            bool                   // DROP ... CASCADE：同时删除依赖要删除的对象的视图、索引和外键，默认为RESTRICT
+	AlterAction        AlterAction            // ALTER TABLE对表的修改，增加列和修改列的类型时列的定义在CreateFields中
+	TableConstraints   []TableConstraint      // 表级约束：ALTER TABLE ADD CONSTRAINT增加的约束，DROP CONSTRAINT时只有约束名
+	Query              *Sql                   // CREATE TABLE ... AS SELECT和INSERT INTO ... SELECT中的查询
 }
 
 // 查询条件
 type Condition struct {
-	Operand1        string   // 操作数1
-	Operand2        string   // 操作数2
-	Operator        Operator // 操作符
-	Operand1IsField bool     // 操作数1是不是某一个列
-	Operand2IsField bool     // 操作数2是不是某一个列
-	IsBetween       bool     // 是否为Between-And语句，不是则BetweenOperand1和2都为nil
-	IsNotBetween    bool     // 是否为Not Between-And语句
-	BetweenOperand1 string   // Between子句操作数1
-	BetweenOperand2 string   // Between字句操作数2
-	IsIn            bool     // 是否为In语句
-	IsNotIn         bool     // 是否为NotIn语句
-	InConditions    []string // In语句的查询条件
+	Operand1        string      // 操作数1
+	Operand2        string      // 操作数2
+	Operator        Operator    // 操作符
+	Operand1IsField bool        // 操作数1是不是某一个列
+	Operand2IsField bool        // 操作数2是不是某一个列
+	IsBetween       bool        // 是否为Between-And语句，不是则BetweenOperand1和2都为nil
+	IsNotBetween    bool        // 是否为Not Between-And语句
+	BetweenOperand1 string      // Between子句操作数1
+	BetweenOperand2 string      // Between字句操作数2
+	IsIn            bool        // 是否为In语句
+	IsNotIn         bool        // 是否为NotIn语句
+	InConditions    []string    // In语句的查询条件
+	Expression1     *Expression // 操作数1的表达式，为nil时由Operand1得到
+	Expression2     *Expression // 操作数2的表达式，为nil时由Operand2得到
+	Subquery        *Sql        // IN、NOT IN、EXISTS、NOT EXISTS的子查询
+	Not             bool        // 条件前面是否有NOT，有NOT时对条件的结果取反
+	// 括号中的一组条件，不为空时这个条件就是括号中的条件，例如(Sage > 18 OR Sno = '3')
+	Group          []Condition
+	GroupOperators []ConditionOperator // 括号中各个条件之间的连接运算符
+}
+
+// 聚集函数，例如COUNT(*)、AVG(Grade)、COUNT(DISTINCT Sno)
+type Aggregate struct {
+	Function   AggregateFunction // 聚集函数的类型
+	Field      string            // 聚集函数的参数的原文，COUNT(*)时为"*"
+	Expression *Expression       // 聚集函数的参数，COUNT(*)时为nil
+	Distinct   bool              // 是否先去掉重复的值再计算
+}
+
+// 聚集函数的类型
+type AggregateFunction int
+
+const (
+	NoAggregate    AggregateFunction = iota // 不是聚集函数
+	CountAggregate                          // 计数：COUNT
+	SumAggregate                            // 求和：SUM
+	AvgAggregate                            // 平均值：AVG
+	MaxAggregate                            // 最大值：MAX
+	MinAggregate                            // 最小值：MIN
+)
+
+var AggregateFunctionString = []string{
+	"",
+	"COUNT",
+	"SUM",
+	"AVG",
+	"MAX",
+	"MIN",
+}
+
+// 聚集函数在查询结果中的列名，例如COUNT(DISTINCT Sno)
+func (aggregate Aggregate) Name() string {
+	if aggregate.Distinct {
+		return AggregateFunctionString[aggregate.Function] + "(DISTINCT " + aggregate.Field + ")"
+	}
+	return AggregateFunctionString[aggregate.Function] + "(" + aggregate.Field + ")"
+}
+
+// 集合运算：UNION、INTERSECT、EXCEPT
+type SetOperation struct {
+	Type   SetOperationType // 集合运算的类型
+	All    bool             // 是否保留重复的行：UNION ALL、INTERSECT ALL、EXCEPT ALL
+	Select *Sql             // 参与运算的SELECT语句
+}
+
+// 集合运算的类型
+type SetOperationType int
+
+const (
+	UnknownSetOperation SetOperationType = iota // 未知的集合运算
+	Union                                       // 并：UNION
+	Intersect                                   // 交：INTERSECT
+	Except                                      // 差：EXCEPT
+)
+
+var SetOperationTypeString = []string{
+	"Unknown",
+	"UNION",
+	"INTERSECT",
+	"EXCEPT",
+}
+
+// FROM子句中用JOIN关键字连接的表
+type Join struct {
+	Type               JoinType            // 连接类型
+	Table              string              // 被连接的表名
+	Conditions         []Condition         // ON子句中的连接条件
+	ConditionOperators []ConditionOperator // ON子句中条件之间的连接符
+	Using              []string            // USING子句中的列名，等价于这些同名列相等的ON条件
+}
+
+// 连接类型
+type JoinType int
+
+const (
+	UnknownJoin JoinType = iota // 未知的连接类型
+	InnerJoin                   // 内连接：JOIN / INNER JOIN
+	LeftJoin                    // 左外连接：LEFT [OUTER] JOIN
+	RightJoin                   // 右外连接：RIGHT [OUTER] JOIN
+	FullJoin                    // 全外连接：FULL [OUTER] JOIN
+	CrossJoin                   // 交叉连接：CROSS JOIN，即笛卡尔积
+)
+
+// 连接关键字对应的连接类型
+var joinTypes = map[string]JoinType{
+	"JOIN":             InnerJoin,
+	"INNER JOIN":       InnerJoin,
+	"LEFT JOIN":        LeftJoin,
+	"LEFT OUTER JOIN":  LeftJoin,
+	"RIGHT JOIN":       RightJoin,
+	"RIGHT OUTER JOIN": RightJoin,
+	"FULL JOIN":        FullJoin,
+	"FULL OUTER JOIN":  FullJoin,
+	"CROSS JOIN":       CrossJoin,
 }
 
 // 该条SQL语句的类型
@@ -65,6 +191,17 @@ const (
 	Grant
 	// 删除用户的权限
 	Revoke
+	// 创建物化视图
+	CreateMaterializedView
+	// 重新计算物化视图
+	RefreshMaterializedView
+	DropTable
+	DropView
+	DropMaterializedView
+	DropIndex
+	DropUser
+	// 修改表的定义
+	AlterTable
 )
 
 var TypeString = []string{
@@ -79,8 +216,29 @@ var TypeString = []string{
 	"Create User",
 	"Grant",
 	"Revoke",
+	"Create Materialized View",
+	"Refresh Materialized View",
+	"Drop Table",
+	"Drop View",
+	"Drop Materialized View",
+	"Drop Index",
+	"Drop User",
+	"Alter Table",
 }
 
+// ALTER TABLE语句对表的修改
+type AlterAction int
+
+const (
+	UnknownAlterAction AlterAction = iota // 未知的修改
+	AddColumn                             // 增加列：ADD COLUMN
+	DropColumn                            // 删除列：DROP COLUMN
+	RenameColumn                          // 修改列名：RENAME COLUMN
+	AlterColumnType                       // 修改列的数据类型：ALTER COLUMN ... TYPE
+	AddConstraint                         // 增加约束：ADD CONSTRAINT
+	DropConstraint                        // 删除约束：DROP CONSTRAINT
+)
+
 // 操作符的类型
 type Operator int
 
@@ -98,6 +256,10 @@ const (
 	NotLike                         // 不相似于Operand2
 	In                              // 必须取值为Operand2的值
 	NotIn                           // 不能是Operand2的值
+	Exists                          // 子查询有结果：EXISTS
+	NotExists                       // 子查询没有结果：NOT EXISTS
+	IsNull                          // 值为空值：IS NULL
+	IsNotNull                       // 值不为空值：IS NOT NULL
 )
 
 var OperatorString = []string{
@@ -152,15 +314,38 @@ var legalWords = []string{
 	"=",
 	">",
 	"<",
+	"||",
+	"+",
+	"-",
+	"*",
+	"/",
+	"%",
 	"SELECT",
 	"INSERT INTO",
 	"VALUES",
 	"UPDATE",
+	"SET NULL",
+	"SET DEFAULT",
 	"SET",
 	"DELETE FROM",
 	"CREATE TABLE",
+	"CREATE MATERIALIZED VIEW",
+	"REFRESH MATERIALIZED VIEW",
 	"CREATE VIEW",
 	"CREATE INDEX",
+	"DROP TABLE",
+	"DROP MATERIALIZED VIEW",
+	"DROP VIEW",
+	"DROP INDEX",
+	"DROP USER",
+	"IF EXISTS",
+	"ALTER TABLE",
+	"ADD COLUMN",
+	"ADD CONSTRAINT",
+	"DROP COLUMN",
+	"DROP CONSTRAINT",
+	"RENAME COLUMN",
+	"ALTER COLUMN",
 	"CREATE USER",
 	"CREATE UNIQUE INDEX",
 	"CREATE CLUSTER INDEX",
@@ -175,6 +360,8 @@ var legalWords = []string{
 	"INSERT",
 	"IN",
 	"NOT IN",
+	"NOT EXISTS",
+	"EXISTS",
 	"LIKE",
 	"NOT LIKE",
 	"GROUP BY",
@@ -184,15 +371,47 @@ var legalWords = []string{
 	"NOT BETWEEN",
 	"IDENTIFIED BY",
 	"ON TABLE",
+	"ON DELETE",
+	"ON UPDATE",
+	"NO ACTION",
 	"TO",
 	"GRANT",
 	"ALL PRIVILEGES",
 	"REVOKE",
+	"IS NOT NULL",
+	"IS NULL",
 	"NOT NULL",
 	"UNIQUE",
 	"PRIMARY KEY",
 	"FOREIGN KEY",
 	"REFERENCES",
+	"INNER JOIN",
+	"LEFT OUTER JOIN",
+	"LEFT JOIN",
+	"RIGHT OUTER JOIN",
+	"RIGHT JOIN",
+	"FULL OUTER JOIN",
+	"FULL JOIN",
+	"CROSS JOIN",
+	"JOIN",
+	"ON",
+	"USING",
+	"DISTINCT",
+	"AS",
+	"LIMIT",
+	"OFFSET",
+	"FETCH FIRST",
+	"FETCH NEXT",
+	"ROWS ONLY",
+	"ROW ONLY",
+	"ROWS",
+	"ROW",
+	"UNION ALL",
+	"UNION",
+	"INTERSECT ALL",
+	"INTERSECT",
+	"EXCEPT ALL",
+	"EXCEPT",
 }
 
 type parser struct {
@@ -202,6 +421,11 @@ type parser struct {
 	step            step   // 当前步骤
 	err             error  // 解析过程中出现的错误
 	nextUpdateField string // 下一个要更新的列
+	inJoinOn        bool   // 当前是否在解析JOIN的ON子句
+	inHaving        bool   // 当前是否在解析HAVING子句
+	inTableCheck    bool   // 当前是否在解析表级的Check约束
+	conditionGroups []int  // 当前所在的括号中的条件组：每一层括号在上一层条件中的下标
+	negateCondition bool   // 下一个条件前面是否有NOT
 }
 
 func Parse(sql string) (parsedSql Sql, err error) {
@@ -252,6 +476,27 @@ func (p *parser) parse() (parsedSql Sql, err error) {
 func (p *parser) doParse() (parsedSql Sql, err error) {
 	for {
 		if p.position >= len(p.sql) {
+			// AND、OR或者NOT后面缺少条件，或者括号中的条件没有结束
+			// EXISTS子查询结束时也停留在stepWhereField，此时条件比连接运算符多一个
+			if conditions, operators := p.conditions(); p.step == stepWhereField && (p.negateCondition || len(*operators) >= len(*conditions)) {
+				return p.query, fmt.Errorf("at WHERE: expected a condition")
+			}
+			if len(p.conditionGroups) > 0 {
+				return p.query, fmt.Errorf("at WHERE: expected closing parens ')'")
+			}
+			// 子句的关键字后面缺少内容
+			switch p.step {
+			case stepSelectGroupByField:
+				return p.query, fmt.Errorf("at GROUP BY: expected field")
+			case stepSelectOrderByField:
+				return p.query, fmt.Errorf("at ORDER BY: expected field")
+			case stepSelectLimitValue:
+				return p.query, fmt.Errorf("at LIMIT: expected number of rows")
+			case stepSelectOffsetValue:
+				return p.query, fmt.Errorf("at OFFSET: expected number of rows")
+			case stepSelectFetchValue, stepSelectFetchRowsOnly:
+				return p.query, fmt.Errorf("at FETCH: expected ROWS ONLY")
+			}
 			return p.query, p.err
 		}
 		switch p.step {
@@ -259,7 +504,14 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			switch strings.ToUpper(p.peek()) {
 			case "SELECT":
 				p.query.Type = Select
+				// 默认不限制返回的行数
+				p.query.Limit = -1
 				p.pop()
+				// SELECT DISTINCT：整行相同的结果只保留一行
+				if strings.ToUpper(p.peek()) == "DISTINCT" {
+					p.query.Distinct = true
+					p.pop()
+				}
 				p.step = stepSelectField
 			case "INSERT INTO":
 				p.query.Type = Insert
@@ -267,7 +519,7 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				p.step = stepInsertTable
 			case "UPDATE":
 				p.query.Type = Update
-				p.query.Updates = map[string]string{}
+				p.query.Updates = map[string]*Expression{}
 				p.pop()
 				p.step = stepUpdateTable
 			case "DELETE FROM":
@@ -282,6 +534,33 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				p.query.Type = CreateView
 				p.pop()
 				p.step = stepCreateViewName
+			case "CREATE MATERIALIZED VIEW":
+				// 物化视图的定义和普通视图相同
+				p.query.Type = CreateMaterializedView
+				p.pop()
+				p.step = stepCreateViewName
+			case "DROP TABLE", "DROP VIEW", "DROP MATERIALIZED VIEW", "DROP INDEX", "DROP USER":
+				p.query.Type = map[string]Type{
+					"DROP TABLE":             DropTable,
+					"DROP VIEW":              DropView,
+					"DROP MATERIALIZED VIEW": DropMaterializedView,
+					"DROP INDEX":             DropIndex,
+					"DROP USER":              DropUser,
+				}[strings.ToUpper(p.peek())]
+				p.pop()
+				if strings.ToUpper(p.peek()) == "IF EXISTS" {
+					p.query.IfExists = true
+					p.pop()
+				}
+				p.step = stepDropName
+			case "ALTER TABLE":
+				p.query.Type = AlterTable
+				p.pop()
+				p.step = stepAlterTableName
+			case "REFRESH MATERIALIZED VIEW":
+				p.query.Type = RefreshMaterializedView
+				p.pop()
+				p.step = stepRefreshViewName
 			case "CREATE UNIQUE INDEX":
 				p.query.Type = CreateIndex
 				p.query.IndexType = "UNIQUE"
@@ -317,8 +596,21 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			// 把表名放入SQL查询的表名中
 			p.query.Tables = append(p.query.Tables, tableName)
 			p.pop()
-			// 下一步：读建表的左括号
-			p.step = stepCreateTableOpeningParens
+			if strings.ToUpper(p.peek()) == "AS" {
+				// CREATE TABLE ... AS SELECT：列的定义由查询结果得到
+				p.step = stepCreateTableAs
+			} else {
+				// 下一步：读建表的左括号
+				p.step = stepCreateTableOpeningParens
+			}
+		case stepCreateTableAs:
+			p.pop()
+			query, err := parseSelect(p.peekToEnd())
+			if err != nil {
+				return p.query, fmt.Errorf("at CREATE TABLE: %v", err)
+			}
+			p.query.Query = &query
+			p.popToEnd()
 		case stepCreateTableOpeningParens:
 			openingParens := p.peek()
 			// 读到的不是左括号
@@ -355,6 +647,26 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				nowField.DataType = Varchar
 			case "DATETIME":
 				nowField.DataType = DateTime
+			case "BOOLEAN":
+				nowField.DataType = Boolean
+			case "BIGINT":
+				nowField.DataType = BigInt
+			case "DECIMAL":
+				// 没有给出精度时为DECIMAL(10,0)
+				nowField.DataType = Decimal
+				nowField.DataLength = defaultDecimalPrecision
+			case "CHAR":
+				// 没有给出长度时为CHAR(1)
+				nowField.DataType = Char
+				nowField.DataLength = 1
+			case "DATE":
+				nowField.DataType = Date
+			case "TIME":
+				nowField.DataType = Time
+			case "TEXT":
+				nowField.DataType = Text
+			case "BLOB":
+				nowField.DataType = Blob
 			default:
 				nowField.DataType = UnknownDataType
 				return p.query, fmt.Errorf("at CREATE TABLE: unknown data type %s", fieldType)
@@ -369,6 +681,9 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			case ",":
 				// 逗号带下一步操作：读逗号
 				p.step = stepCreateTableComma
+			case ")":
+				// 右括号的下一步操作：表定义结束
+				p.step = stepCreateTableClosingParens
 			default:
 				// 其他字符的下一步操作：确定约束类型
 				p.step = stepCreateTableConstraintType
@@ -393,6 +708,21 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			nowField := &p.query.CreateFields[len(p.query.CreateFields)-1]
 			nowField.DataLength = int(fieldLengthInt)
 			p.pop()
+			if nowField.DataType == Decimal {
+				// DECIMAL(p,s)：逗号后面是小数位数
+				if p.peek() == "," {
+					p.pop()
+					scale, err := strconv.ParseInt(p.peek(), 10, 64)
+					if err != nil {
+						return p.query, fmt.Errorf("at CREATE TABLE: scale %s is not an integer", p.peek())
+					}
+					nowField.Scale = int(scale)
+					p.pop()
+				}
+				if nowField.DataLength < 1 || nowField.DataLength > maxDecimalPrecision || nowField.Scale < 0 || nowField.Scale > nowField.DataLength {
+					return p.query, fmt.Errorf("at CREATE TABLE: invalid DECIMAL(%d,%d)", nowField.DataLength, nowField.Scale)
+				}
+			}
 			// 下一步操作：读右括号
 			p.step = stepCreateTableFieldClosingParens
 		case stepCreateTableFieldClosingParens:
@@ -429,6 +759,7 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				nowField.Unique = true
 			case "PRIMARY KEY":
 				nowField.Constraint = append(nowField.Constraint, Constraint{ConstraintType: PrimaryKey})
+				nowField.PrimaryKey = true
 			case "CHECK":
 			case "DEFAULT":
 				nowField.Constraint = append(nowField.Constraint, Constraint{ConstraintType: Default})
@@ -443,6 +774,14 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			} else {
 				// 约束判断完毕，弹出，判断下一个是什么
 				p.pop()
+				// DEFAULT后面是默认值的表达式，保存表达式的原文，插入时再计算
+				if strings.ToUpper(constraintType) == "DEFAULT" {
+					_, text, err := p.parseExpression()
+					if err != nil {
+						return p.query, fmt.Errorf("at CREATE TABLE: invalid DEFAULT of field %s: %v", nowField.Name, err)
+					}
+					nowField.Default = text
+				}
 				nextIdentifier := p.peek()
 				switch nextIdentifier {
 				case ",":
@@ -451,9 +790,14 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				case ")":
 					// 本表已经定义完成，转表定义结束的右括号
 					p.step = stepCreateTableClosingParens
+				case "":
+					// ALTER TABLE ADD COLUMN的列定义到语句末尾结束
+					if p.query.Type != AlterTable {
+						return p.query, fmt.Errorf("at CREATE TABLE: expected closing parens: ')'")
+					}
 				default:
-					// 其他非法标识符
-					return p.query, fmt.Errorf("at CREATE TABLE: unexpected token: %s", nextIdentifier)
+					// 一列可以有多个约束，例如NOT NULL DEFAULT 0，其他非法标识符在下一步报错
+					p.step = stepCreateTableConstraintType
 				}
 			}
 		case stepCheck:
@@ -475,10 +819,10 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			p.step = stepCheckField
 		case stepCheckField:
 			field := p.peek()
-			// 取出当前列
-			nowField := &p.query.CreateFields[len(p.query.CreateFields)-1]
+			// 取出当前Check约束的条件
+			checkConditions, _ := p.checkConditions()
 			// 设置Check约束条件
-			nowField.CheckConditions = append(nowField.CheckConditions, Condition{
+			*checkConditions = append(*checkConditions, Condition{
 				Operand1:        field,
 				Operand1IsField: true,
 			})
@@ -487,10 +831,10 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			p.step = stepCheckOperator
 		case stepCheckOperator:
 			operator := p.peek()
-			// 取出当前列
-			nowField := &p.query.CreateFields[len(p.query.CreateFields)-1]
+			// 取出当前Check约束的条件
+			checkConditions, _ := p.checkConditions()
 			// 拿到当前操作的Check条件
-			currentCondition := &nowField.CheckConditions[len(nowField.CheckConditions)-1]
+			currentCondition := &(*checkConditions)[len(*checkConditions)-1]
 			// 判断操作符
 			switch operator {
 			case "=":
@@ -518,6 +862,17 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 					currentCondition.Operator = In
 					// In需要跳转到In约束条件
 					p.step = stepCheckIn
+				case "IS NULL", "IS NOT NULL":
+					// IS NULL和IS NOT NULL没有需要检查的值，下一个记号是右括号、And或者Or
+					currentCondition.Operator = IsNull
+					if operator == "IS NOT NULL" {
+						currentCondition.Operator = IsNotNull
+					}
+					p.pop()
+					if err := p.stepAfterCheckCondition(); err != nil {
+						return p.query, err
+					}
+					continue
 				default:
 					currentCondition.Operator = UnknownOperator
 					return p.query, fmt.Errorf("at CHECK: unknown operator")
@@ -530,27 +885,20 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			}
 		case stepCheckValue:
 			// 取得Check约束的检查值
-			checkValue := p.peek()
-			// 取出当前列
-			nowField := &p.query.CreateFields[len(p.query.CreateFields)-1]
+			checkValue := p.peekValue()
+			// 取出当前Check约束的条件
+			checkConditions, _ := p.checkConditions()
 			// 拿到当前操作的Check条件
-			currentCondition := &nowField.CheckConditions[len(nowField.CheckConditions)-1]
+			currentCondition := &(*checkConditions)[len(*checkConditions)-1]
 			// 设置Check约束的值
 			currentCondition.Operand2 = checkValue
-			currentCondition.Operand2IsField = false
+			// 没有单引号并且以字母或下划线开头的检查值是列名，例如CHECK (lo < hi)
+			currentCondition.Operand2IsField = checkValue != "" && p.sql[p.position] != '\'' &&
+				isWordChar(checkValue[0]) && (checkValue[0] < '0' || checkValue[0] > '9')
 			// 赋值完毕，弹出这个值，判断下一个值
 			p.pop()
-			nextIdentifier := p.peek()
-			switch strings.ToUpper(nextIdentifier) {
-			case ")":
-				// 读到左括号，跳转到左括号的条件
-				p.step = stepCheckClosingParens
-			case "AND":
-				p.step = stepCheckAnd
-			case "OR":
-				p.step = stepCheckOr
-			default:
-				return p.query, fmt.Errorf("at CHECK: unexpected token %s", nextIdentifier)
+			if err := p.stepAfterCheckCondition(); err != nil {
+				return p.query, err
 			}
 		case stepCheckIn:
 			in := p.peek()
@@ -558,10 +906,10 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			if strings.ToUpper(in) != "IN" {
 				return p.query, fmt.Errorf("at CHECK: expected IN")
 			}
-			// 取出当前列
-			nowField := &p.query.CreateFields[len(p.query.CreateFields)-1]
+			// 取出当前Check约束的条件
+			checkConditions, _ := p.checkConditions()
 			// 拿到当前操作的Check条件，设置操作符为In
-			currentCondition := &nowField.CheckConditions[len(nowField.CheckConditions)-1]
+			currentCondition := &(*checkConditions)[len(*checkConditions)-1]
 			currentCondition.Operator = In
 			p.pop()
 			// 下一步：读左括号
@@ -576,11 +924,11 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			// 下一步：读In运算的值
 			p.step = stepCheckInValue
 		case stepCheckInValue:
-			value := p.peek()
-			// 取出当前列
-			nowField := &p.query.CreateFields[len(p.query.CreateFields)-1]
+			value := p.peekValue()
+			// 取出当前Check约束的条件
+			checkConditions, _ := p.checkConditions()
 			// 拿到当前操作的Check条件，设置为In，并赋值
-			currentCondition := &nowField.CheckConditions[len(nowField.CheckConditions)-1]
+			currentCondition := &(*checkConditions)[len(*checkConditions)-1]
 			currentCondition.IsIn = true
 			currentCondition.InConditions = append(currentCondition.InConditions, value)
 			p.pop()
@@ -608,9 +956,15 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				return p.query, fmt.Errorf("at CHECK: expected closing parens ')'")
 			}
 			p.pop()
+			p.inTableCheck = false
 			// Check字句定义结束，下一步：继续定义下一个列
 			nextIdentifier := p.peek()
-			if nextIdentifier == "," {
+			if p.query.Type == AlterTable {
+				// ALTER TABLE ADD CONSTRAINT的Check约束到语句末尾结束
+				if nextIdentifier != "" {
+					return p.query, fmt.Errorf("at ALTER TABLE: unexpected %s", nextIdentifier)
+				}
+			} else if nextIdentifier == "," {
 				p.step = stepCreateTableComma
 			} else {
 				p.step = stepCreateTableClosingParens
@@ -621,10 +975,10 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			if strings.ToUpper(and) != "AND" {
 				return p.query, fmt.Errorf("at CHECK: expected AND")
 			}
-			// 取出当前列
-			nowField := &p.query.CreateFields[len(p.query.CreateFields)-1]
+			// 取出当前Check约束的连接运算符
+			_, checkOperators := p.checkConditions()
 			// Check子句运算符运算条件设置为And
-			nowField.CheckConditionsOperator = append(nowField.CheckConditionsOperator, And)
+			*checkOperators = append(*checkOperators, And)
 			p.pop()
 			// 下一步：继续解析下一条Check子句
 			p.step = stepCheckField
@@ -634,10 +988,10 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			if strings.ToUpper(or) != "OR" {
 				return p.query, fmt.Errorf("at CHECK: expected OR")
 			}
-			// 取出当前列
-			nowField := &p.query.CreateFields[len(p.query.CreateFields)-1]
+			// 取出当前Check约束的连接运算符
+			_, checkOperators := p.checkConditions()
 			// Check字句运算符运算条件设置为Or
-			nowField.CheckConditionsOperator = append(nowField.CheckConditionsOperator, Or)
+			*checkOperators = append(*checkOperators, Or)
 			p.pop()
 			// 下一步：继续解析下一条Check子句
 			p.step = stepCheckField
@@ -663,13 +1017,16 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			case ")":
 				// 是右括号，则表定义结束
 				p.step = stepCreateTableClosingParens
-			case "PRIMARY KEY":
-				// 跳转主键约束
+			case "PRIMARY KEY", "UNIQUE":
+				// 跳转主键或者唯一约束
 				p.step = stepPrimaryKey
 			case "FOREIGN KEY":
 				// 跳转外键约束
 				p.step = stepForeignKey
 			case "CHECK":
+				// 表级Check约束，条件记录在表级约束中
+				p.query.TableConstraints = append(p.query.TableConstraints, TableConstraint{ConstraintType: Check})
+				p.inTableCheck = true
 				// 跳转到Check约束
 				p.step = stepCheck
 			default:
@@ -678,9 +1035,15 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			}
 		case stepPrimaryKey:
 			primaryKey := p.peek()
-			// 读到的不是主键关键字
-			if strings.ToUpper(primaryKey) != "PRIMARY KEY" {
-				return p.query, fmt.Errorf("at CREATE TABLE: expected PRIMARY KEY")
+			// 表级的主键和唯一约束，约束中的列先记录在表级约束中
+			switch strings.ToUpper(primaryKey) {
+			case "PRIMARY KEY":
+				p.query.TableConstraints = append(p.query.TableConstraints, TableConstraint{ConstraintType: PrimaryKey})
+			case "UNIQUE":
+				p.query.TableConstraints = append(p.query.TableConstraints, TableConstraint{ConstraintType: Unique})
+			default:
+				// 读到的不是主键关键字
+				return p.query, fmt.Errorf("at CREATE TABLE: expected PRIMARY KEY or UNIQUE")
 			}
 			p.pop()
 			// 下一步：读左括号
@@ -709,11 +1072,12 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			if flag == false {
 				return p.query, fmt.Errorf("at CREATE TABLE: unknown field %s", fieldName)
 			}
-			field := &p.query.CreateFields[i]
-			// 约束类型：主键
-			field.Constraint = append(field.Constraint, Constraint{ConstraintType: PrimaryKey})
-			// 设置该列为主键
-			field.PrimaryKey = true
+			constraint := &p.query.TableConstraints[len(p.query.TableConstraints)-1]
+			if containsString(constraint.Fields, p.query.CreateFields[i].Name) {
+				return p.query, fmt.Errorf("at CREATE TABLE: field %s appears twice in the constraint", fieldName)
+			}
+			// 记录约束中的列
+			constraint.Fields = append(constraint.Fields, p.query.CreateFields[i].Name)
 			p.pop()
 			// 下一步：读逗号或右括号
 			p.step = stepPrimaryKeyCommaOrClosingParens
@@ -731,6 +1095,23 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			if commaOrClosingParens == ")" {
 				// 读到右括号，表示Primary Key约束定义完成
 				p.pop()
+				constraint := p.query.TableConstraints[len(p.query.TableConstraints)-1]
+				if len(constraint.Fields) == 1 {
+					// 只有一列的约束和列级约束一样，记录在列中
+					for index := range p.query.CreateFields {
+						field := &p.query.CreateFields[index]
+						if field.Name != constraint.Fields[0] {
+							continue
+						}
+						field.Constraint = append(field.Constraint, Constraint{ConstraintType: constraint.ConstraintType})
+						if constraint.ConstraintType == PrimaryKey {
+							field.PrimaryKey = true
+						} else {
+							field.Unique = true
+						}
+					}
+					p.query.TableConstraints = p.query.TableConstraints[:len(p.query.TableConstraints)-1]
+				}
 				if p.peek() == "," {
 					// 读到逗号，说明还有其他字段
 					p.step = stepCreateTableComma
@@ -833,8 +1214,6 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			}
 			nowField := &p.query.CreateFields[i]
 			nowField.ForeignKeyReferenceField = fieldName
-			// 该字段定义完成
-			nowField.ForeignKeyFlag = false
 			p.pop()
 			// 下一步：读右括号
 			p.step = stepForeignKeyReferenceFieldClosingParens
@@ -846,25 +1225,66 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			}
 			p.pop()
 			// 根据读到的内容，判断下一步操作
-			nextIdentifier := p.peek()
-			switch nextIdentifier {
-			case ",":
-				// 读到逗号说明还有其他字段
-				p.step = stepCreateTableComma
-			case ")":
-				// 读到右括号说明表定义已经结束
-				p.step = stepCreateTableClosingParens
+			if err := p.stepAfterForeignKey(); err != nil {
+				return p.query, err
+			}
+		case stepForeignKeyAction:
+			// ON DELETE或者ON UPDATE，后面是参照动作
+			event := strings.ToUpper(p.pop())
+			var action ReferentialAction
+			switch strings.ToUpper(p.peek()) {
+			case "CASCADE":
+				action = Cascade
+			case "SET NULL":
+				action = SetNull
+			case "SET DEFAULT":
+				action = SetDefault
+			case "RESTRICT":
+				action = Restrict
+			case "NO ACTION":
+				action = NoAction
 			default:
-				return p.query, fmt.Errorf("at CREATE TABLE: unexpected token %s", nextIdentifier)
+				return p.query, fmt.Errorf("at FOREIGN KEY: unknown referential action %s", p.peek())
+			}
+			p.pop()
+			onDelete, onUpdate := p.foreignKeyActions()
+			if event == "ON DELETE" {
+				*onDelete = action
+			} else {
+				*onUpdate = action
+			}
+			// 判断后面是否还有参照动作
+			if err := p.stepAfterForeignKey(); err != nil {
+				return p.query, err
 			}
 		case stepSelectField:
-			field := p.peek()
-			if !isIdentifierOrAsterisk(field) {
-				return p.query, fmt.Errorf("at SELECT: expected field from SELECT")
+			var expression *Expression
+			name := p.peek()
+			if name == "*" {
+				// *单独作为一列，展开为所有的列
+				expression = &Expression{Type: FieldExpression, Value: p.pop()}
+			} else {
+				// 读取查询的列：列名、聚集函数或者表达式
+				var err error
+				expression, name, err = p.parseExpression()
+				if err != nil {
+					return p.query, fmt.Errorf("at SELECT: %v", err)
+				}
+			}
+			// 读取列的别名：列名 AS 别名
+			alias := ""
+			if strings.ToUpper(p.peek()) == "AS" {
+				p.pop()
+				alias = p.peek()
+				if !isIdentifier(alias) || strings.Contains(alias, ".") {
+					return p.query, fmt.Errorf("at SELECT: expected alias after AS")
+				}
+				p.pop()
 			}
 			// 将读到的字段放入解析出的字段中
-			p.query.Fields = append(p.query.Fields, field)
-			p.pop()
+			p.query.Fields = append(p.query.Fields, name)
+			p.query.FieldExpressions = append(p.query.FieldExpressions, expression)
+			p.query.Aliases = append(p.query.Aliases, alias)
 			// 读下一个标识符，根据是否为FROM判断是否还有其他字段
 			nextIdentifier := p.peek()
 			if strings.ToUpper(nextIdentifier) == "FROM" {
@@ -891,20 +1311,17 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			// 下一步：读表名
 			p.step = stepSelectFromTable
 		case stepSelectFromTable:
-			tableName := p.peek()
-			if len(tableName) == 0 {
-				return p.query, fmt.Errorf("at SELECT: expected quoted table name")
+			tableName, err := p.popFromTable()
+			if err != nil {
+				return p.query, err
 			}
-			p.query.Tables = append(p.query.Tables, tableName)
-			p.pop()
-			nextIdentifier := p.peek()
-			if nextIdentifier == "," {
-				// 读到的是逗号，说明还没有读完，读逗号
-				p.step = stepSelectFromTableComma
+			if len(p.query.Joins) > 0 {
+				// 已经出现过JOIN，逗号之后的表与前面的结果做交叉连接
+				p.query.Joins = append(p.query.Joins, Join{Type: CrossJoin, Table: tableName})
 			} else {
-				// 表名读取完毕，跳转到Where子句
-				p.step = stepWhere
+				p.query.Tables = append(p.query.Tables, tableName)
 			}
+			p.stepAfterFromTable()
 		case stepSelectFromTableComma:
 			comma := p.peek()
 			// 读取到的不是逗号
@@ -914,6 +1331,275 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			// 弹出这个逗号，开始读下一个表名
 			p.pop()
 			p.step = stepSelectFromTable
+		case stepSelectJoin:
+			join := p.peek()
+			joinType, ok := joinTypes[strings.ToUpper(join)]
+			// 读到的不是连接关键字
+			if !ok {
+				return p.query, fmt.Errorf("at SELECT: expected JOIN")
+			}
+			p.query.Joins = append(p.query.Joins, Join{Type: joinType})
+			p.pop()
+			// 下一步：读被连接的表名
+			p.step = stepSelectJoinTable
+		case stepSelectJoinTable:
+			tableName, err := p.popFromTable()
+			if err != nil {
+				return p.query, err
+			}
+			currentJoin := &p.query.Joins[len(p.query.Joins)-1]
+			currentJoin.Table = tableName
+			// 根据下一个记号判断是ON子句还是USING子句，交叉连接没有连接条件
+			nextIdentifier := p.peek()
+			switch strings.ToUpper(nextIdentifier) {
+			case "ON":
+				p.step = stepSelectJoinOn
+			case "USING":
+				p.step = stepSelectJoinUsing
+			default:
+				if currentJoin.Type != CrossJoin {
+					return p.query, fmt.Errorf("at JOIN: expected ON or USING")
+				}
+				p.stepAfterFromTable()
+			}
+		case stepSelectJoinOn:
+			on := p.peek()
+			if strings.ToUpper(on) != "ON" {
+				return p.query, fmt.Errorf("at JOIN: expected ON")
+			}
+			p.pop()
+			// ON子句的条件与Where子句的条件写法相同，解析到的条件放入当前的连接中
+			p.inJoinOn = true
+			p.step = stepWhereField
+		case stepSelectJoinUsing:
+			using := p.peek()
+			if strings.ToUpper(using) != "USING" {
+				return p.query, fmt.Errorf("at JOIN: expected USING")
+			}
+			p.pop()
+			// 下一步：读左括号
+			p.step = stepSelectJoinUsingOpeningParens
+		case stepSelectJoinUsingOpeningParens:
+			openingParens := p.peek()
+			if openingParens != "(" {
+				return p.query, fmt.Errorf("at USING: expected opening parens '('")
+			}
+			p.pop()
+			// 下一步：读列名
+			p.step = stepSelectJoinUsingField
+		case stepSelectJoinUsingField:
+			field := p.peek()
+			if !isIdentifier(field) {
+				return p.query, fmt.Errorf("at USING: expected field")
+			}
+			currentJoin := &p.query.Joins[len(p.query.Joins)-1]
+			currentJoin.Using = append(currentJoin.Using, field)
+			p.pop()
+			// 下一步：读逗号或右括号
+			p.step = stepSelectJoinUsingCommaOrClosingParens
+		case stepSelectJoinUsingCommaOrClosingParens:
+			commaOrClosingParens := p.peek()
+			if commaOrClosingParens != "," && commaOrClosingParens != ")" {
+				return p.query, fmt.Errorf("at USING: expected comma ',' or closing parens ')'")
+			}
+			p.pop()
+			if commaOrClosingParens == "," {
+				p.step = stepSelectJoinUsingField
+			} else {
+				// USING子句结束，判断FROM子句是否结束
+				p.stepAfterFromTable()
+			}
+		case stepSelectGroupBy:
+			groupBy := p.peek()
+			if strings.ToUpper(groupBy) != "GROUP BY" {
+				return p.query, fmt.Errorf("at SELECT: expected GROUP BY")
+			}
+			p.pop()
+			// 下一步：读分组的列
+			p.step = stepSelectGroupByField
+		case stepSelectGroupByField:
+			field := p.peek()
+			if !isIdentifier(field) {
+				return p.query, fmt.Errorf("at GROUP BY: expected field")
+			}
+			p.query.GroupByFields = append(p.query.GroupByFields, field)
+			p.pop()
+			// 根据下一个记号判断是否还有其他分组的列
+			nextIdentifier := p.peek()
+			if nextIdentifier == "," {
+				p.step = stepSelectGroupByComma
+			} else if nextIdentifier == "" {
+				// 已经读到语句末尾
+				p.step = stepSelectEnd
+			} else if !p.stepSelectClause(nextIdentifier) {
+				return p.query, fmt.Errorf("at GROUP BY: unexpected token %s", nextIdentifier)
+			}
+		case stepSelectGroupByComma:
+			comma := p.peek()
+			if comma != "," {
+				return p.query, fmt.Errorf("at GROUP BY: expected comma ','")
+			}
+			p.pop()
+			// 下一步：读下一个分组的列
+			p.step = stepSelectGroupByField
+		case stepSelectHaving:
+			having := p.peek()
+			if strings.ToUpper(having) != "HAVING" {
+				return p.query, fmt.Errorf("at SELECT: expected HAVING")
+			}
+			p.pop()
+			// HAVING子句的条件与Where子句的条件写法相同，解析到的条件放入HAVING子句中
+			p.inHaving = true
+			p.step = stepWhereField
+		case stepSelectOrderBy:
+			orderBy := p.peek()
+			if strings.ToUpper(orderBy) != "ORDER BY" {
+				return p.query, fmt.Errorf("at SELECT: expected ORDER BY")
+			}
+			p.pop()
+			// 下一步：读排序的列
+			p.step = stepSelectOrderByField
+		case stepSelectOrderByField:
+			// 读取排序的列：列名、聚集函数或者表达式
+			expression, name, err := p.parseExpression()
+			if err != nil {
+				return p.query, fmt.Errorf("at ORDER BY: %v", err)
+			}
+			// 没有指定排序方向时默认升序
+			p.query.OrderByFields = append(p.query.OrderByFields, name)
+			p.query.OrderByArrangement = append(p.query.OrderByArrangement, "ASC")
+			p.query.OrderByExpressions = append(p.query.OrderByExpressions, expression)
+			if err := p.stepAfterOrderByField(); err != nil {
+				return p.query, err
+			}
+		case stepSelectOrderByAscOrDesc:
+			ascOrDesc := strings.ToUpper(p.peek())
+			if ascOrDesc != "ASC" && ascOrDesc != "DESC" {
+				return p.query, fmt.Errorf("at ORDER BY: expected ASC or DESC")
+			}
+			p.query.OrderByArrangement[len(p.query.OrderByArrangement)-1] = ascOrDesc
+			p.pop()
+			if err := p.stepAfterOrderByField(); err != nil {
+				return p.query, err
+			}
+		case stepSelectOrderByComma:
+			comma := p.peek()
+			if comma != "," {
+				return p.query, fmt.Errorf("at ORDER BY: expected comma ','")
+			}
+			p.pop()
+			// 下一步：读下一个排序的列
+			p.step = stepSelectOrderByField
+		case stepSelectLimit:
+			limit := p.peek()
+			if strings.ToUpper(limit) != "LIMIT" {
+				return p.query, fmt.Errorf("at SELECT: expected LIMIT")
+			}
+			p.pop()
+			// 下一步：读最多返回的行数
+			p.step = stepSelectLimitValue
+		case stepSelectLimitValue:
+			value := p.peek()
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 0 {
+				return p.query, fmt.Errorf("at LIMIT: %s is not a non-negative integer", value)
+			}
+			p.query.Limit = count
+			p.pop()
+			if err := p.stepAfterPagination(); err != nil {
+				return p.query, err
+			}
+		case stepSelectOffset:
+			offset := p.peek()
+			if strings.ToUpper(offset) != "OFFSET" {
+				return p.query, fmt.Errorf("at SELECT: expected OFFSET")
+			}
+			p.pop()
+			// 下一步：读跳过的行数
+			p.step = stepSelectOffsetValue
+		case stepSelectOffsetValue:
+			value := p.peek()
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 0 {
+				return p.query, fmt.Errorf("at OFFSET: %s is not a non-negative integer", value)
+			}
+			p.query.Offset = count
+			p.pop()
+			// OFFSET m后面可以有ROWS关键字
+			nextIdentifier := strings.ToUpper(p.peek())
+			if nextIdentifier == "ROWS" || nextIdentifier == "ROW" {
+				p.pop()
+			}
+			if err := p.stepAfterPagination(); err != nil {
+				return p.query, err
+			}
+		case stepSelectFetch:
+			fetch := strings.ToUpper(p.peek())
+			if fetch != "FETCH FIRST" && fetch != "FETCH NEXT" {
+				return p.query, fmt.Errorf("at SELECT: expected FETCH FIRST or FETCH NEXT")
+			}
+			p.pop()
+			// 下一步：读最多返回的行数
+			p.step = stepSelectFetchValue
+		case stepSelectFetchValue:
+			value := p.peek()
+			if upper := strings.ToUpper(value); upper == "ROW ONLY" || upper == "ROWS ONLY" {
+				// 省略行数时只返回一行：FETCH FIRST ROW ONLY
+				p.query.Limit = 1
+			} else {
+				count, err := strconv.Atoi(value)
+				if err != nil || count < 0 {
+					return p.query, fmt.Errorf("at FETCH: %s is not a non-negative integer", value)
+				}
+				p.query.Limit = count
+				p.pop()
+			}
+			// 下一步：读ROWS ONLY
+			p.step = stepSelectFetchRowsOnly
+		case stepSelectFetchRowsOnly:
+			rowsOnly := strings.ToUpper(p.peek())
+			if rowsOnly != "ROWS ONLY" && rowsOnly != "ROW ONLY" {
+				return p.query, fmt.Errorf("at FETCH: expected ROWS ONLY")
+			}
+			p.pop()
+			if err := p.stepAfterPagination(); err != nil {
+				return p.query, err
+			}
+		case stepSelectSetOperation:
+			operator := strings.ToUpper(p.peek())
+			operation := SetOperation{All: strings.HasSuffix(operator, " ALL")}
+			switch strings.TrimSuffix(operator, " ALL") {
+			case "UNION":
+				operation.Type = Union
+			case "INTERSECT":
+				operation.Type = Intersect
+			case "EXCEPT":
+				operation.Type = Except
+			default:
+				return p.query, fmt.Errorf("at SELECT: expected UNION, INTERSECT or EXCEPT")
+			}
+			// ORDER BY和分页作用于整个集合运算的结果，只能写在最后一个SELECT语句之后
+			if len(p.query.OrderByFields) > 0 || p.query.Limit >= 0 || p.query.Offset > 0 {
+				return p.query, fmt.Errorf("at %s: ORDER BY, LIMIT and OFFSET must follow the last SELECT", operator)
+			}
+			p.pop()
+			// 其余部分作为另一个SELECT语句解析，它后面的集合运算也一起解析出来
+			next, err := parseSelect(p.sql[p.position:])
+			if err != nil {
+				return p.query, fmt.Errorf("at %s: %v", operator, err)
+			}
+			p.position = len(p.sql)
+			// 最后一个SELECT语句的ORDER BY和分页属于整个集合运算
+			p.query.OrderByFields, next.OrderByFields = next.OrderByFields, nil
+			p.query.OrderByArrangement, next.OrderByArrangement = next.OrderByArrangement, nil
+			p.query.OrderByExpressions, next.OrderByExpressions = next.OrderByExpressions, nil
+			p.query.Limit, next.Limit = next.Limit, -1
+			p.query.Offset, next.Offset = next.Offset, 0
+			// 按顺序展开：A UNION B INTERSECT C 得到 A 和 [UNION B, INTERSECT C]
+			operations := next.SetOperations
+			next.SetOperations = nil
+			operation.Select = &next
+			p.query.SetOperations = append(append(p.query.SetOperations, operation), operations...)
 		case stepInsertTable:
 			tableName := p.peek()
 			// 如果读到的表名长度为0
@@ -925,6 +1611,11 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			// 下一步：读左括号
 			p.step = stepInsertFieldsOpeningParens
 		case stepInsertFieldsOpeningParens:
+			// INSERT INTO ... SELECT和INSERT INTO ... VALUES可以省略列名，按照表中列的顺序插入
+			if strings.ToUpper(p.peek()) == "SELECT" || strings.ToUpper(p.peek()) == "VALUES" {
+				p.step = stepInsertValue
+				continue
+			}
 			openingParens := p.peek()
 			// 读到的不是左括号
 			if len(openingParens) != 1 || openingParens != "(" {
@@ -960,6 +1651,16 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				p.step = stepInsertValue
 			}
 		case stepInsertValue:
+			// INSERT INTO ... SELECT：查询结果作为插入的数据
+			if strings.ToUpper(p.peek()) == "SELECT" {
+				query, err := parseSelect(p.peekToEnd())
+				if err != nil {
+					return p.query, fmt.Errorf("at INSERT INTO: %v", err)
+				}
+				p.query.Query = &query
+				p.popToEnd()
+				continue
+			}
 			values := p.peek()
 			// 读到的不是VALUES
 			if strings.ToUpper(values) != "VALUES" {
@@ -980,7 +1681,15 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			// 下一步：读需要插入的数值
 			p.step = stepInsertValues
 		case stepInsertValues:
-			value := p.peek()
+			value := p.peekValue()
+			// 负数：负号和后面的数字是两个记号
+			if value == "-" && p.sql[p.position] != '\'' {
+				p.pop()
+				if !IsNum(p.peek()) {
+					return p.query, fmt.Errorf("at INSERT INTO: expected number after '-'")
+				}
+				value = "-" + p.peek()
+			}
 			// 将读到的数值放入待插入的数组中
 			p.query.Inserts[len(p.query.Inserts)-1] = append(p.query.Inserts[len(p.query.Inserts)-1], value)
 			p.pop()
@@ -1051,11 +1760,14 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			// 下一步：读字段值
 			p.step = stepUpdateValue
 		case stepUpdateValue:
-			value := p.peek()
+			// 字段的新值可以是表达式，例如SET Sage = Sage + 1
+			expression, _, err := p.parseExpression()
+			if err != nil {
+				return p.query, fmt.Errorf("at UPDATE: %v", err)
+			}
 			// 将字段值放入要更新的字段列表中
-			p.query.Updates[p.nextUpdateField] = value
+			p.query.Updates[p.nextUpdateField] = expression
 			p.nextUpdateField = ""
-			p.pop()
 			// 根据下一个标识符决定进行什么操作
 			nextIdentifier := p.peek()
 			// 读到的是where，跳转到Where子句解析
@@ -1096,18 +1808,59 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			// 下一步：读取要被Where所判断的列
 			p.step = stepWhereField
 		case stepWhereField:
-			field := p.peek()
-			// 读到的列名不合法
-			if !isIdentifier(field) {
-				return p.query, fmt.Errorf("at WHERE: expected field")
+			// NOT对后面的一个条件或者一组括号中的条件取反，NOT NOT相互抵消
+			if strings.ToUpper(p.peek()) == "NOT" {
+				p.pop()
+				p.negateCondition = !p.negateCondition
+				continue
 			}
-			p.query.Conditions = append(p.query.Conditions, Condition{Operand1: field, Operand1IsField: true})
-			p.pop()
+			// 括号中的一组条件：先计算括号中的条件，再和括号外的条件做And或者Or运算
+			if p.peekConditionGroup() {
+				p.pop()
+				conditions, _ := p.conditions()
+				*conditions = append(*conditions, Condition{Not: p.negateCondition})
+				p.negateCondition = false
+				// 之后的条件都放入这一组中，直到读到右括号
+				p.conditionGroups = append(p.conditionGroups, len(*conditions)-1)
+				continue
+			}
+			// EXISTS和NOT EXISTS后面直接是子查询，没有左边的操作数
+			if exists := strings.ToUpper(p.peek()); exists == "EXISTS" || exists == "NOT EXISTS" {
+				p.pop()
+				subquery, err := p.popSubquery()
+				if err != nil {
+					return p.query, err
+				}
+				condition := Condition{Operator: Exists, Subquery: subquery, Not: p.negateCondition}
+				p.negateCondition = false
+				if exists == "NOT EXISTS" {
+					condition.Operator = NotExists
+				}
+				conditions, _ := p.conditions()
+				*conditions = append(*conditions, condition)
+				if err := p.stepAfterWhereCondition(); err != nil {
+					return p.query, err
+				}
+				continue
+			}
+			// 条件的左边可以是列名或者表达式，HAVING子句中一般是聚集函数
+			expression, text, err := p.parseExpression()
+			if err != nil {
+				return p.query, fmt.Errorf("at WHERE: %v", err)
+			}
+			conditions, _ := p.conditions()
+			*conditions = append(*conditions, Condition{
+				Operand1:        text,
+				Operand1IsField: expression.Type == FieldExpression,
+				Expression1:     expression,
+				Not:             p.negateCondition,
+			})
+			p.negateCondition = false
 			// 下一步：读取Where子句的操作符
 			p.step = stepWhereOperator
 		case stepWhereOperator:
 			operator := p.peek()
-			currentCondition := &p.query.Conditions[len(p.query.Conditions)-1]
+			currentCondition := p.currentCondition()
 			switch operator {
 			case "=":
 				currentCondition.Operator = Eq
@@ -1137,6 +1890,17 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			case "NOT BETWEEN":
 				currentCondition.Operator = NotBetween
 				p.step = stepWhereNotBetween
+			case "IS NULL", "IS NOT NULL":
+				// IS NULL和IS NOT NULL没有右边的值，直接根据下一个记号判断Where子句是否结束
+				currentCondition.Operator = IsNull
+				if operator == "IS NOT NULL" {
+					currentCondition.Operator = IsNotNull
+				}
+				p.pop()
+				if err := p.stepAfterWhereCondition(); err != nil {
+					return p.query, err
+				}
+				continue
 			default:
 				return p.query, fmt.Errorf("at WHERE: unknown operator")
 			}
@@ -1145,26 +1909,22 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				p.step = stepWhereValue
 			}
 		case stepWhereValue:
-			whereValue := p.peek()
+			// 条件的右边可以是字面值、列名或者表达式，例如连接条件Student.Sno = SC.Sno
+			expression, text, err := p.parseExpression()
+			if err != nil {
+				return p.query, fmt.Errorf("at WHERE: %v", err)
+			}
 			// 拿到当前操作的Where条件子句
-			currentCondition := &p.query.Conditions[len(p.query.Conditions)-1]
+			currentCondition := p.currentCondition()
 			// 为当前的Where操作赋值
-			currentCondition.Operand2 = whereValue
-			currentCondition.Operand2IsField = false
-			// 赋值完毕，弹出这个值，判断下一个值
-			p.pop()
-			nextIdentifier := p.peek()
-			switch strings.ToUpper(nextIdentifier) {
-			case "AND":
-				p.step = stepWhereAnd
-			case "OR":
-				p.step = stepWhereOr
-			case "IN":
-				p.step = stepWhereIn
-			case "NOT IN":
-				p.step = stepWhereNotIn
-			case "BETWEEN":
-				p.step = stepWhereBetween
+			currentCondition.Operand2 = text
+			if expression.Type == LiteralExpression {
+				currentCondition.Operand2 = expression.Value
+			}
+			currentCondition.Operand2IsField = expression.Type == FieldExpression
+			currentCondition.Expression2 = expression
+			if err := p.stepAfterWhereCondition(); err != nil {
+				return p.query, err
 			}
 		case stepWhereAnd:
 			and := p.peek()
@@ -1173,7 +1933,8 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				return p.query, fmt.Errorf("expected AND")
 			}
 			// 放入一个And，表示Where的第一、二个子句之间的操作条件是And
-			p.query.ConditionOperators = append(p.query.ConditionOperators, And)
+			_, operators := p.conditions()
+			*operators = append(*operators, And)
 			p.pop()
 			// 下一步：读下一个要被操作的列
 			p.step = stepWhereField
@@ -1184,7 +1945,8 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				return p.query, fmt.Errorf("expected OR")
 			}
 			// 放入一个OR，表示Where的第一二个子句之间的操作条件为OR
-			p.query.ConditionOperators = append(p.query.ConditionOperators, Or)
+			_, operators := p.conditions()
+			*operators = append(*operators, Or)
 			p.pop()
 			// 下一步：读取下一个要被操作的列
 			p.step = stepWhereField
@@ -1195,7 +1957,7 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				return p.query, fmt.Errorf("at WHERE: expected IN")
 			}
 			// 获得当前正在操作的条件
-			currentCondition := &p.query.Conditions[len(p.query.Conditions)-1]
+			currentCondition := p.currentCondition()
 			currentCondition.IsIn = true
 			p.pop()
 			// 下一步：读左括号
@@ -1207,12 +1969,24 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				return p.query, fmt.Errorf("at WHERE: expected NOT IN")
 			}
 			// 获得当前正在操作的条件
-			currentCondition := &p.query.Conditions[len(p.query.Conditions)-1]
+			currentCondition := p.currentCondition()
 			currentCondition.IsNotIn = true
 			p.pop()
 			// 下一步：读左括号
 			p.step = stepWhereInOpeningParens
 		case stepWhereInOpeningParens:
+			// IN (SELECT ...)：值在子查询的结果中
+			if p.peekSubquery() {
+				subquery, err := p.popSubquery()
+				if err != nil {
+					return p.query, err
+				}
+				p.currentCondition().Subquery = subquery
+				if err := p.stepAfterWhereCondition(); err != nil {
+					return p.query, err
+				}
+				continue
+			}
 			openingParens := p.peek()
 			// 读到的不是左括号
 			if openingParens != "(" {
@@ -1222,9 +1996,9 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			// 下一步：读具体数值
 			p.step = stepWhereInValue
 		case stepWhereInValue:
-			value := p.peek()
+			value := p.peekValue()
 			// 获得当前正在操作的条件
-			currentCondition := &p.query.Conditions[len(p.query.Conditions)-1]
+			currentCondition := p.currentCondition()
 			// 将读取到的值追加到In操作符条件中
 			currentCondition.InConditions = append(currentCondition.InConditions, value)
 			p.pop()
@@ -1241,9 +2015,11 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				p.pop()
 			}
 			if commaOrClosingParens == ")" {
-				// 读到左括号，表示In语句定义完毕，跳转到Where结束
-				p.step = stepWhere
+				// 读到右括号，表示In语句定义完毕，根据下一个记号判断Where子句是否结束
 				p.pop()
+				if err := p.stepAfterWhereCondition(); err != nil {
+					return p.query, err
+				}
 			}
 		case stepWhereBetween:
 			between := p.peek()
@@ -1251,6 +2027,10 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			if between != "BETWEEN" {
 				return p.query, fmt.Errorf("expected BETWEEN")
 			}
+			// 拿到当前操作的Where条件子句
+			currentCondition := p.currentCondition()
+			// 是一个Between语句
+			currentCondition.IsBetween = true
 			p.pop()
 			// 下一步：读第一个操作数
 			p.step = stepWhereBetweenValue
@@ -1261,20 +2041,18 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 				return p.query, fmt.Errorf("expected NOT BETWEEN")
 			}
 			// 拿到当前操作的Where条件子句
-			currentCondition := &p.query.Conditions[len(p.query.Conditions)-1]
+			currentCondition := p.currentCondition()
 			// 是一个Not-Between语句
 			currentCondition.IsNotBetween = true
 			p.pop()
 			// 下一步：读第一个操作数
 			p.step = stepWhereBetweenValue
 		case stepWhereBetweenValue:
-			value := p.peek()
+			value := p.peekValue()
 			// 拿到当前操作的Where条件子句
-			currentCondition := &p.query.Conditions[len(p.query.Conditions)-1]
-			// 设置具体数值：Between与And之间是操作数1
-			currentCondition.Operand1 = value
-			// Between-And中肯定不会出现列名
-			currentCondition.Operand1IsField = false
+			currentCondition := p.currentCondition()
+			// 设置具体数值：Between与And之间是Between操作数1，操作数1仍然是被判断的列
+			currentCondition.BetweenOperand1 = value
 			p.pop()
 			// 下一步：读AND
 			p.step = stepWhereBetweenAnd
@@ -1288,16 +2066,16 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			// 下一步：读第二个操作数
 			p.step = stepWhereBetweenAndValue
 		case stepWhereBetweenAndValue:
-			value := p.peek()
+			value := p.peekValue()
 			// 拿到当前操作的Where条件子句
-			currentCondition := &p.query.Conditions[len(p.query.Conditions)-1]
-			// 设置具体数值：And之后是操作数2
-			currentCondition.Operand2 = value
-			// Between-And中肯定不会出现列名
-			currentCondition.Operand2IsField = false
-			p.pop()
-			// Between-And语句处理完成，返回
-			p.step = stepWhere
+			currentCondition := p.currentCondition()
+			// 设置具体数值：And之后是Between操作数2
+			currentCondition.BetweenOperand2 = value
+			p.pop()
+			// Between-And语句处理完成，根据下一个记号判断Where子句是否结束
+			if err := p.stepAfterWhereCondition(); err != nil {
+				return p.query, err
+			}
 		case stepCreateViewName:
 			name := p.peek()
 			if !isIdentifierOrAsterisk(name) {
@@ -1305,7 +2083,12 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			}
 			p.query.Tables = append(p.query.Tables, name)
 			p.pop()
-			p.step = stepCreateViewOpeningParens
+			// 没有列名时直接是AS
+			if strings.ToUpper(p.peek()) == "AS" {
+				p.step = stepCreateViewAs
+			} else {
+				p.step = stepCreateViewOpeningParens
+			}
 		case stepCreateViewOpeningParens:
 			openingParens := p.peek()
 			if openingParens != "(" {
@@ -1342,9 +2125,241 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 			p.step = stepCreateViewSelect
 		case stepCreateViewSelect:
 			selectSql := p.peekToEnd()
+			// 末尾的WITH CHECK OPTION不属于SELECT语句
+			if loc := withCheckOptionRegexp.FindStringIndex(selectSql); loc != nil {
+				// 物化视图是只读的，不能使用WITH CHECK OPTION
+				if p.query.Type == CreateMaterializedView {
+					return p.query, fmt.Errorf("at CREATE MATERIALIZED VIEW: WITH CHECK OPTION is not allowed")
+				}
+				p.query.WithCheckOption = true
+				selectSql = selectSql[:loc[0]]
+			}
+			// 检查视图的SELECT语句是否合法，查询视图时再重新解析
+			if _, err := parseSelect(selectSql); err != nil {
+				return p.query, fmt.Errorf("at CREATE VIEW: %v", err)
+			}
 			p.query.ViewSelect = selectSql
 			p.popToEnd()
 			p.step = stepCreateViewName
+		case stepAlterTableName:
+			tableName := p.peek()
+			if !isIdentifier(tableName) {
+				return p.query, fmt.Errorf("at ALTER TABLE: expected table name to ALTER")
+			}
+			p.query.Tables = append(p.query.Tables, tableName)
+			p.pop()
+			p.step = stepAlterTableAction
+		case stepAlterTableAction:
+			action := strings.ToUpper(p.pop())
+			switch action {
+			case "ADD COLUMN", "ADD":
+				// 列的定义和建表时相同
+				p.query.AlterAction = AddColumn
+				p.step = stepCreateTableField
+			case "DROP COLUMN", "DROP":
+				p.query.AlterAction = DropColumn
+				p.step = stepAlterTableColumn
+			case "RENAME COLUMN":
+				p.query.AlterAction = RenameColumn
+				p.step = stepAlterTableColumn
+			case "ALTER COLUMN":
+				p.query.AlterAction = AlterColumnType
+				p.step = stepAlterTableColumn
+			case "ADD CONSTRAINT":
+				p.query.AlterAction = AddConstraint
+				p.step = stepAlterTableConstraintName
+			case "DROP CONSTRAINT":
+				p.query.AlterAction = DropConstraint
+				p.step = stepAlterTableConstraintName
+			default:
+				return p.query, fmt.Errorf("at ALTER TABLE: unknown action %s", action)
+			}
+		case stepAlterTableColumn:
+			fieldName := p.peek()
+			if !isIdentifier(fieldName) {
+				return p.query, fmt.Errorf("at ALTER TABLE: expected column name")
+			}
+			p.query.Fields = append(p.query.Fields, fieldName)
+			p.pop()
+			switch p.query.AlterAction {
+			case RenameColumn:
+				if strings.ToUpper(p.pop()) != "TO" {
+					return p.query, fmt.Errorf("at RENAME COLUMN: expected TO")
+				}
+				p.step = stepAlterTableNewColumn
+			case AlterColumnType:
+				if strings.ToUpper(p.pop()) != "TYPE" {
+					return p.query, fmt.Errorf("at ALTER COLUMN: expected TYPE")
+				}
+				// 新的数据类型和建表时的写法相同
+				p.query.CreateFields = append(p.query.CreateFields, Field{Name: fieldName})
+				p.step = stepCreateTableFieldType
+			default:
+				if rest := p.peek(); rest != "" {
+					return p.query, fmt.Errorf("at ALTER TABLE: unexpected %s", rest)
+				}
+			}
+		case stepAlterTableNewColumn:
+			fieldName := p.peek()
+			if !isIdentifier(fieldName) {
+				return p.query, fmt.Errorf("at RENAME COLUMN: expected new column name")
+			}
+			p.query.Fields = append(p.query.Fields, fieldName)
+			p.pop()
+			if rest := p.peek(); rest != "" {
+				return p.query, fmt.Errorf("at ALTER TABLE: unexpected %s", rest)
+			}
+		case stepAlterTableConstraintName:
+			name := p.peek()
+			if !isIdentifier(name) {
+				return p.query, fmt.Errorf("at ALTER TABLE: expected constraint name")
+			}
+			p.query.TableConstraints = append(p.query.TableConstraints, TableConstraint{Name: name})
+			p.pop()
+			if p.query.AlterAction == AddConstraint {
+				p.step = stepAlterTableConstraintType
+			} else if rest := p.peek(); rest != "" {
+				return p.query, fmt.Errorf("at ALTER TABLE: unexpected %s", rest)
+			}
+		case stepAlterTableConstraintType:
+			constraint := &p.query.TableConstraints[len(p.query.TableConstraints)-1]
+			constraintType := strings.ToUpper(p.pop())
+			switch constraintType {
+			case "UNIQUE":
+				constraint.ConstraintType = Unique
+			case "PRIMARY KEY":
+				constraint.ConstraintType = PrimaryKey
+			case "FOREIGN KEY":
+				constraint.ConstraintType = ForeignKey
+			case "CHECK":
+				constraint.ConstraintType = Check
+			default:
+				return p.query, fmt.Errorf("at ADD CONSTRAINT: unknown constraint type %s", constraintType)
+			}
+			if constraint.ConstraintType == Check {
+				// Check约束的条件和CREATE TABLE中的Check约束一样解析
+				p.inTableCheck = true
+				p.step = stepCheckOpeningParens
+			} else {
+				p.step = stepAlterTableConstraintOpeningParens
+			}
+		case stepAlterTableConstraintOpeningParens:
+			if p.pop() != "(" {
+				return p.query, fmt.Errorf("at ADD CONSTRAINT: expected opening parens '('")
+			}
+			p.step = stepAlterTableConstraintField
+		case stepAlterTableConstraintField:
+			fieldName := p.peek()
+			if !isIdentifier(fieldName) {
+				return p.query, fmt.Errorf("at ADD CONSTRAINT: expected column name")
+			}
+			constraint := &p.query.TableConstraints[len(p.query.TableConstraints)-1]
+			constraint.Fields = append(constraint.Fields, fieldName)
+			p.pop()
+			p.step = stepAlterConstraintCommaOrClosingParens
+		case stepAlterConstraintCommaOrClosingParens:
+			constraint := &p.query.TableConstraints[len(p.query.TableConstraints)-1]
+			switch p.pop() {
+			case ",":
+				p.step = stepAlterTableConstraintField
+			case ")":
+				if constraint.ConstraintType == ForeignKey {
+					p.step = stepAlterTableReferences
+				} else if rest := p.peek(); rest != "" {
+					return p.query, fmt.Errorf("at ALTER TABLE: unexpected %s", rest)
+				}
+			default:
+				return p.query, fmt.Errorf("at ADD CONSTRAINT: expected comma ',' or closing parens ')'")
+			}
+		case stepAlterTableReferences:
+			if strings.ToUpper(p.pop()) != "REFERENCES" {
+				return p.query, fmt.Errorf("at ADD CONSTRAINT: expected REFERENCES")
+			}
+			p.step = stepAlterTableReferenceTable
+		case stepAlterTableReferenceTable:
+			tableName := p.peek()
+			if !isIdentifier(tableName) {
+				return p.query, fmt.Errorf("at ADD CONSTRAINT: expected referenced table name")
+			}
+			constraint := &p.query.TableConstraints[len(p.query.TableConstraints)-1]
+			constraint.ReferenceTable = tableName
+			p.pop()
+			if p.pop() != "(" {
+				return p.query, fmt.Errorf("at ADD CONSTRAINT: expected opening parens '('")
+			}
+			p.step = stepAlterTableReferenceField
+		case stepAlterTableReferenceField:
+			fieldName := p.peek()
+			if !isIdentifier(fieldName) {
+				return p.query, fmt.Errorf("at ADD CONSTRAINT: expected referenced column name")
+			}
+			constraint := &p.query.TableConstraints[len(p.query.TableConstraints)-1]
+			constraint.ReferenceField = fieldName
+			p.pop()
+			if p.pop() != ")" {
+				return p.query, fmt.Errorf("at ADD CONSTRAINT: expected closing parens ')'")
+			}
+			if err := p.stepAfterForeignKey(); err != nil {
+				return p.query, err
+			}
+		case stepRefreshViewName:
+			name := p.peek()
+			if !isIdentifier(name) {
+				return p.query, fmt.Errorf("at REFRESH MATERIALIZED VIEW: expected materialized view name")
+			}
+			p.query.Tables = append(p.query.Tables, name)
+			p.pop()
+			if rest := p.peek(); rest != "" {
+				return p.query, fmt.Errorf("at REFRESH MATERIALIZED VIEW: unexpected %s", rest)
+			}
+		case stepDropName:
+			operation := strings.ToUpper(TypeString[p.query.Type])
+			name := p.peek()
+			if !isIdentifier(name) {
+				return p.query, fmt.Errorf("at %s: expected name to DROP", operation)
+			}
+			switch p.query.Type {
+			case DropIndex:
+				// 一条语句只能删除一个索引
+				if p.query.IndexName != "" {
+					return p.query, fmt.Errorf("at DROP INDEX: only one index can be dropped at a time")
+				}
+				p.query.IndexName = name
+			case DropUser:
+				p.query.Users = append(p.query.Users, name)
+			default:
+				p.query.Tables = append(p.query.Tables, name)
+			}
+			p.pop()
+			p.step = stepDropCommaOrBehavior
+		case stepDropCommaOrBehavior:
+			operation := strings.ToUpper(TypeString[p.query.Type])
+			next := strings.ToUpper(p.peek())
+			switch {
+			case next == ",":
+				p.pop()
+				p.step = stepDropName
+			case next == "ON" && p.query.Type == DropIndex && len(p.query.Tables) == 0:
+				// DROP INDEX 索引名 ON 表名：只删除这个表上的索引
+				p.pop()
+				p.step = stepDropIndexTable
+			case (next == "CASCADE" || next == "RESTRICT") && p.query.Type != DropIndex && p.query.Type != DropUser:
+				p.query.Cascade = next == "CASCADE"
+				p.pop()
+				if rest := p.peek(); rest != "" {
+					return p.query, fmt.Errorf("at %s: unexpected %s", operation, rest)
+				}
+			default:
+				return p.query, fmt.Errorf("at %s: unexpected %s", operation, p.peek())
+			}
+		case stepDropIndexTable:
+			tableName := p.peek()
+			if !isIdentifier(tableName) {
+				return p.query, fmt.Errorf("at DROP INDEX: expected table name after ON")
+			}
+			p.query.Tables = append(p.query.Tables, tableName)
+			p.pop()
+			p.step = stepDropCommaOrBehavior
 		case stepCreateIndexName:
 			name := p.peek()
 			if !isIdentifierOrAsterisk(name) {
@@ -1644,6 +2659,246 @@ func (p *parser) doParse() (parsedSql Sql, err error) {
 	}
 }
 
+// Where子句中的一个条件解析完成后，根据下一个记号决定下一步：And、Or或者Where子句结束
+func (p *parser) stepAfterWhereCondition() error {
+	nextIdentifier := p.peek()
+	switch strings.ToUpper(nextIdentifier) {
+	case "AND":
+		p.step = stepWhereAnd
+	case "OR":
+		p.step = stepWhereOr
+	case ")":
+		// 括号中的一组条件结束，回到上一层条件，再根据右括号后面的记号决定下一步
+		if len(p.conditionGroups) == 0 {
+			return fmt.Errorf("at WHERE: unexpected closing parens ')'")
+		}
+		p.pop()
+		p.conditionGroups = p.conditionGroups[:len(p.conditionGroups)-1]
+		return p.stepAfterWhereCondition()
+	case "":
+		// 已经读到语句末尾，Where子句结束
+	default:
+		// 括号中的条件还没有结束
+		if len(p.conditionGroups) > 0 {
+			return fmt.Errorf("at WHERE: expected closing parens ')'")
+		}
+		if p.inJoinOn {
+			// ON子句结束，后面是FROM子句的其余部分
+			p.inJoinOn = false
+			p.stepAfterFromTable()
+			return nil
+		}
+		if p.query.Type == Select && p.stepSelectClause(nextIdentifier) {
+			// Where或者HAVING子句结束，后面是其他子句
+			p.inHaving = false
+			return nil
+		}
+		return fmt.Errorf("at WHERE: unexpected token %s", nextIdentifier)
+	}
+	return nil
+}
+
+// Check约束中的一个条件解析完成后，根据下一个记号决定下一步
+func (p *parser) stepAfterCheckCondition() error {
+	nextIdentifier := p.peek()
+	switch strings.ToUpper(nextIdentifier) {
+	case ")":
+		// 读到右括号，跳转到右括号的条件
+		p.step = stepCheckClosingParens
+	case "AND":
+		p.step = stepCheckAnd
+	case "OR":
+		p.step = stepCheckOr
+	default:
+		return fmt.Errorf("at CHECK: unexpected token %s", nextIdentifier)
+	}
+	return nil
+}
+
+// ORDER BY子句中的一个列解析完成后，根据下一个记号决定下一步
+func (p *parser) stepAfterOrderByField() error {
+	nextIdentifier := p.peek()
+	switch strings.ToUpper(nextIdentifier) {
+	case ",":
+		p.step = stepSelectOrderByComma
+	case "ASC", "DESC":
+		p.step = stepSelectOrderByAscOrDesc
+	case "":
+		// 已经读到语句末尾
+		p.step = stepSelectEnd
+	default:
+		if !p.stepSelectClause(nextIdentifier) {
+			return fmt.Errorf("at ORDER BY: unexpected token %s", nextIdentifier)
+		}
+	}
+	return nil
+}
+
+// SELECT语句中FROM和Where之后的子句，根据关键字跳转到对应的步骤，返回是否是这样的子句
+func (p *parser) stepSelectClause(keyword string) bool {
+	switch strings.ToUpper(keyword) {
+	case "GROUP BY":
+		p.step = stepSelectGroupBy
+	case "HAVING":
+		p.step = stepSelectHaving
+	case "ORDER BY":
+		p.step = stepSelectOrderBy
+	case "LIMIT":
+		p.step = stepSelectLimit
+	case "OFFSET":
+		p.step = stepSelectOffset
+	case "FETCH FIRST", "FETCH NEXT":
+		p.step = stepSelectFetch
+	case "UNION", "UNION ALL", "INTERSECT", "INTERSECT ALL", "EXCEPT", "EXCEPT ALL":
+		p.step = stepSelectSetOperation
+	default:
+		return false
+	}
+	return true
+}
+
+// LIMIT、OFFSET或者FETCH子句解析完成后，根据下一个记号决定下一步
+func (p *parser) stepAfterPagination() error {
+	nextIdentifier := p.peek()
+	switch strings.ToUpper(nextIdentifier) {
+	case "LIMIT":
+		p.step = stepSelectLimit
+	case "OFFSET":
+		p.step = stepSelectOffset
+	case "FETCH FIRST", "FETCH NEXT":
+		p.step = stepSelectFetch
+	case "UNION", "UNION ALL", "INTERSECT", "INTERSECT ALL", "EXCEPT", "EXCEPT ALL":
+		// 交给stepSelectSetOperation报错：分页只能出现在最后一个SELECT语句之后
+		p.step = stepSelectSetOperation
+	case "":
+		// 已经读到语句末尾
+		p.step = stepSelectEnd
+	default:
+		return fmt.Errorf("at SELECT: unexpected token %s", nextIdentifier)
+	}
+	return nil
+}
+
+// FROM子句中的一个表解析完成后，根据下一个记号决定下一步：逗号、JOIN、Where子句或者其他子句
+func (p *parser) stepAfterFromTable() {
+	nextIdentifier := strings.ToUpper(p.peek())
+	if p.stepSelectClause(nextIdentifier) {
+		return
+	}
+	if nextIdentifier == "," {
+		// 读到的是逗号，说明还没有读完，读逗号
+		p.step = stepSelectFromTableComma
+	} else if _, ok := joinTypes[nextIdentifier]; ok {
+		// 读到的是连接关键字
+		p.step = stepSelectJoin
+	} else {
+		// 表名读取完毕，跳转到Where子句
+		p.step = stepWhere
+	}
+}
+
+// 当前正在解析的条件列表：ON子句中的条件属于最后一个连接，其他情况属于Where子句
+func (p *parser) conditions() (conditions *[]Condition, operators *[]ConditionOperator) {
+	switch {
+	case p.inHaving:
+		conditions, operators = &p.query.HavingConditions, &p.query.HavingOperators
+	case p.inJoinOn:
+		currentJoin := &p.query.Joins[len(p.query.Joins)-1]
+		conditions, operators = &currentJoin.Conditions, &currentJoin.ConditionOperators
+	default:
+		conditions, operators = &p.query.Conditions, &p.query.ConditionOperators
+	}
+	// 在括号中时，条件放入最内层括号的条件组中
+	for _, index := range p.conditionGroups {
+		group := &(*conditions)[index]
+		conditions, operators = &group.Group, &group.GroupOperators
+	}
+	return conditions, operators
+}
+
+// 判断下一个左括号是否开始一组条件：括号中是子查询，或者括号开始的表达式后面是比较运算符时，左括号属于表达式
+// 例如(Sage + 1) > 20中的括号属于表达式，(Sage > 18 OR Sno = '3')中的括号是一组条件
+func (p *parser) peekConditionGroup() bool {
+	if p.peek() != "(" || p.peekSubquery() {
+		return false
+	}
+	position := p.position
+	defer func() { p.position = position }()
+	if _, _, err := p.parseExpression(); err != nil {
+		return true
+	}
+	switch strings.ToUpper(p.peek()) {
+	case "=", ">", ">=", "<", "<=", "!=", "LIKE", "NOT LIKE", "IN", "NOT IN", "BETWEEN", "NOT BETWEEN", "IS NULL", "IS NOT NULL":
+		return false
+	}
+	return true
+}
+
+// 外键的被参照列定义完成之后：后面可以有ON DELETE和ON UPDATE的参照动作，否则外键定义结束
+func (p *parser) stepAfterForeignKey() error {
+	nextIdentifier := p.peek()
+	switch strings.ToUpper(nextIdentifier) {
+	case "ON DELETE", "ON UPDATE":
+		p.step = stepForeignKeyAction
+		return nil
+	}
+	// 外键定义结束
+	for index := range p.query.CreateFields {
+		p.query.CreateFields[index].ForeignKeyFlag = false
+	}
+	if p.query.Type == AlterTable {
+		// ALTER TABLE ADD CONSTRAINT的外键到语句末尾结束
+		if nextIdentifier != "" {
+			return fmt.Errorf("at ALTER TABLE: unexpected %s", nextIdentifier)
+		}
+		return nil
+	}
+	switch nextIdentifier {
+	case ",":
+		// 读到逗号说明还有其他字段
+		p.step = stepCreateTableComma
+	case ")":
+		// 读到右括号说明表定义已经结束
+		p.step = stepCreateTableClosingParens
+	default:
+		return fmt.Errorf("at CREATE TABLE: unexpected token %s", nextIdentifier)
+	}
+	return nil
+}
+
+// 当前正在定义的外键的参照动作：ALTER TABLE中属于最后一个表级约束，CREATE TABLE中属于正在定义外键的列
+func (p *parser) foreignKeyActions() (onDelete *ReferentialAction, onUpdate *ReferentialAction) {
+	if p.query.Type == AlterTable {
+		constraint := &p.query.TableConstraints[len(p.query.TableConstraints)-1]
+		return &constraint.OnDelete, &constraint.OnUpdate
+	}
+	i := 0
+	for index, field := range p.query.CreateFields {
+		// 拿到当前操作的字段
+		if field.ForeignKeyFlag == true {
+			i = index
+		}
+	}
+	nowField := &p.query.CreateFields[i]
+	return &nowField.OnDelete, &nowField.OnUpdate
+}
+
+// 当前正在解析的Check约束的条件列表：表级Check约束属于最后一个表级约束，列级Check约束属于当前列
+func (p *parser) checkConditions() (conditions *[]Condition, operators *[]ConditionOperator) {
+	if p.inTableCheck {
+		constraint := &p.query.TableConstraints[len(p.query.TableConstraints)-1]
+		return &constraint.Conditions, &constraint.ConditionOperators
+	}
+	nowField := &p.query.CreateFields[len(p.query.CreateFields)-1]
+	return &nowField.CheckConditions, &nowField.CheckConditionsOperator
+}
+
+// 当前正在解析的最后一个条件
+func (p *parser) currentCondition() *Condition {
+	conditions, _ := p.conditions()
+	return &(*conditions)[len(*conditions)-1]
+}
+
 // 检验生成的SQL语句是否合法
 func (p *parser) validate() error {
 	// WHERE语句的条件为空
@@ -1710,6 +2965,15 @@ func (p *parser) peek() (peeked string) {
 	return peeked
 }
 
+// 返回但不弹出下一个值：没有单引号的NULL是空值NullValue，'NULL'仍然是字符串
+func (p *parser) peekValue() (value string) {
+	value = p.peek()
+	if strings.ToUpper(value) == "NULL" && p.sql[p.position] != '\'' {
+		return NullValue
+	}
+	return value
+}
+
 // 弹出解析的下一个记号
 func (p *parser) pop() (peeked string) {
 	// 得到下一个记号，并把当前解析位置移动到下一个记号后
@@ -1743,9 +3007,15 @@ func (p *parser) peekWithLength() (string, int) {
 	// 合法字符
 	for _, lw := range legalWords {
 		token := strings.ToUpper(p.sql[p.position:min(len(p.sql), p.position+len(lw))])
-		if token == lw {
-			return token, len(token)
+		if token != lw {
+			continue
+		}
+		// 关键字后面紧跟着字母、数字或下划线，说明这是一个以关键字开头的标识符，例如Online、Total
+		end := p.position + len(lw)
+		if isWordChar(lw[len(lw)-1]) && end < len(p.sql) && isWordChar(p.sql[end]) {
+			continue
 		}
+		return token, len(token)
 	}
 
 	// 有单引号的字句
@@ -1777,7 +3047,12 @@ func (p *parser) peekQuotedStringWithLength() (identifier string, length int) {
 func (p *parser) peekIdentifierWithLength() (identifier string, length int) {
 	for i := p.position; i < len(p.sql); i++ {
 		// 不在语句的最后
-		if matched, _ := regexp.MatchString(`[a-zA-Z0-9_*]`, string(p.sql[i])); !matched {
+		// 小数点也可以出现在记号中，例如浮点数3.5
+		// 星号只能紧跟在小数点后面，例如Student.*，否则是乘号
+		if p.sql[i] == '*' && i > p.position && p.sql[i-1] == '.' {
+			continue
+		}
+		if matched, _ := regexp.MatchString(`[a-zA-Z0-9_.]`, string(p.sql[i])); !matched {
 			return p.sql[p.position:i], len(p.sql[p.position:i])
 		}
 	}
@@ -1808,6 +3083,11 @@ func isIdentifier(s string) (result bool) {
 	return matched
 }
 
+// 判断一个字符是否可以出现在标识符中
+func isWordChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
 // 打印错误信息
 func (p *parser) logError() {
 	// 打印错误的SQL语句和错误原因
@@ -1825,6 +3105,15 @@ func min(a, b int) (min int) {
 	}
 }
 
+// 返回两个数中较大的一个
+func max(a, b int) (max int) {
+	if a > b {
+		return a
+	} else {
+		return b
+	}
+}
+
 // 判断一个字符串是否是浮点数
 func IsNum(s string) bool {
 	_, err := strconv.ParseFloat(s, 64)
@@ -0,0 +1,634 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 查询过程中的中间结果
+// 表文件按列存储数据，而连接、筛选等操作都是以行为单位的，所以中间结果按行存储
+type resultSet struct {
+	fields []resultField // 中间结果中的列
+	rows   [][]string    // 中间结果中的行，每一行的值与fields一一对应
+	outer  valueGetter   // 相关子查询中外层查询当前行的valueGetter，本查询中没有的列到外层查询中查找
+}
+
+// 中间结果中的列
+type resultField struct {
+	table  string // 该列所属的表名
+	field  Field  // 该列的定义
+	hidden bool   // 被USING合并掉的列，只能通过"表名.列名"访问
+}
+
+// 把列的存储结构转换为列的定义
+func toField(field FieldJson) Field {
+	return Field{
+		Name:                     field.Name,
+		DataType:                 field.DataType,
+		DataLength:               field.DataLength,
+		Scale:                    field.Scale,
+		Constraint:               nil,
+		CheckConditions:          nil,
+		CheckConditionsOperator:  nil,
+		PrimaryKey:               field.PrimaryKey,
+		NotNull:                  field.NotNull,
+		Unique:                   field.Unique,
+		ForeignKey:               field.ForeignKey,
+		ForeignKeyFlag:           false,
+		ForeignKeyReferenceTable: field.ForeignKeyTable,
+		ForeignKeyReferenceField: field.ForeignKeyColumn,
+	}
+}
+
+// 只有表中的列、没有数据的中间结果，用于在计算之前检查表达式
+func tableSchema(table *TableJson) *resultSet {
+	set := &resultSet{}
+	for _, field := range table.Fields {
+		set.fields = append(set.fields, resultField{table: table.Name, field: toField(field)})
+	}
+	return set
+}
+
+// 把按列存储的表转换为按行存储的中间结果
+func tableResultSet(table *TableJson) *resultSet {
+	set := tableSchema(table)
+	for row := 0; row < tableRowCount(table); row++ {
+		values := nullValues(len(table.Fields))
+		for index, field := range table.Fields {
+			// 该列的数据比其他列少，缺少的部分视为空值
+			if row < len(field.Data) {
+				values[index] = field.Data[row]
+			}
+		}
+		set.rows = append(set.rows, values)
+	}
+	return set
+}
+
+// 根据列名找到列的下标，列名可以是"列名"，也可以是"表名.列名"
+func (set *resultSet) fieldIndex(name string) (index int, err error) {
+	index, count := set.findField(name)
+	switch {
+	case count == 0:
+		return -1, fmt.Errorf("unknown field %s", name)
+	case count > 1:
+		// 不带表名的列名在多个表中都存在
+		return -1, fmt.Errorf("ambiguous field %s", name)
+	}
+	return index, nil
+}
+
+// 查找列名匹配的列，返回第一个匹配的列的下标和匹配的列数
+func (set *resultSet) findField(name string) (index int, count int) {
+	// 聚集函数等计算出来的列不属于任何表，列名完全相同才能匹配
+	for i, field := range set.fields {
+		if field.table == "" && field.field.Name == name {
+			return i, 1
+		}
+	}
+	tableName, fieldName := "", name
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		tableName, fieldName = name[:dot], name[dot+1:]
+	}
+	index = -1
+	for i, field := range set.fields {
+		if field.field.Name != fieldName || (tableName != "" && field.table != tableName) {
+			continue
+		}
+		if tableName == "" && field.hidden {
+			continue
+		}
+		if index < 0 {
+			index = i
+		}
+		count++
+	}
+	return index, count
+}
+
+// 返回用于读取中间结果中某一行数据的valueGetter
+func (set *resultSet) rowGetter(row []string) valueGetter {
+	return func(fieldName string) (value string, dataType DataType, err error) {
+		// 相关子查询：本查询中没有的列是外层查询的列
+		if _, count := set.findField(fieldName); count == 0 && set.outer != nil {
+			return set.outer(fieldName)
+		}
+		index, err := set.fieldIndex(fieldName)
+		if err != nil {
+			return "", UnknownDataType, fmt.Errorf("at WHERE: %v", err)
+		}
+		return row[index], set.fields[index].field.DataType, nil
+	}
+}
+
+// 返回值都为空值、只有数据类型的valueGetter，用于在计算之前推断子查询结果的类型
+func (set *resultSet) schemaGetter() valueGetter {
+	return set.rowGetter(nullValues(len(set.fields)))
+}
+
+// 返回count个空值，用于补齐缺少的数据
+func nullValues(count int) []string {
+	values := make([]string, count)
+	for index := range values {
+		values[index] = NullValue
+	}
+	return values
+}
+
+// 判断条件中用到的列是否都在中间结果中
+func (set *resultSet) canEvaluate(condition Condition) bool {
+	// 括号中的一组条件：其中所有的条件都能计算时才能计算
+	if len(condition.Group) > 0 {
+		for _, member := range condition.Group {
+			if !set.canEvaluate(member) {
+				return false
+			}
+		}
+		return true
+	}
+	// 带有子查询的条件可能用到任意的列，等所有的表都连接完成之后再计算
+	if condition.Subquery != nil || condition.Expression1.hasSubquery() || condition.Expression2.hasSubquery() {
+		return false
+	}
+	left := conditionExpression(condition.Expression1, condition.Operand1, condition.Operand1IsField)
+	right := conditionExpression(condition.Expression2, condition.Operand2, condition.Operand2IsField)
+	for _, fieldName := range append(left.fields(true), right.fields(true)...) {
+		if _, err := set.fieldIndex(fieldName); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// 检查条件中不带表名的列名是否在多个表中都存在，不存在的列可能是外层查询的列，留到计算时再处理
+func (set *resultSet) checkAmbiguousFields(conditions []Condition) error {
+	for _, condition := range conditions {
+		if err := set.checkAmbiguousFields(condition.Group); err != nil {
+			return err
+		}
+		left := conditionExpression(condition.Expression1, condition.Operand1, condition.Operand1IsField)
+		right := conditionExpression(condition.Expression2, condition.Operand2, condition.Operand2IsField)
+		for _, fieldName := range append(left.fields(true), right.fields(true)...) {
+			if _, count := set.findField(fieldName); count > 1 {
+				return fmt.Errorf("at WHERE: ambiguous field %s", fieldName)
+			}
+		}
+	}
+	return nil
+}
+
+// 在计算之前检查条件中的表达式，运算符和函数的参数类型不正确时报错
+func (set *resultSet) checkConditions(conditions []Condition) error {
+	for _, condition := range conditions {
+		if len(condition.Group) > 0 {
+			if err := set.checkConditions(condition.Group); err != nil {
+				return err
+			}
+			continue
+		}
+		left := conditionExpression(condition.Expression1, condition.Operand1, condition.Operand1IsField)
+		right := conditionExpression(condition.Expression2, condition.Operand2, condition.Operand2IsField)
+		for _, expression := range []*Expression{left, right} {
+			if _, err := expression.dataType(set); err != nil {
+				return fmt.Errorf("at WHERE: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// 用条件筛选中间结果中的行
+func (set *resultSet) filter(conditions []Condition, operators []ConditionOperator) (result *resultSet, err error) {
+	if err := set.checkConditions(conditions); err != nil {
+		return nil, err
+	}
+	result = &resultSet{fields: set.fields, outer: set.outer}
+	for _, row := range set.rows {
+		matched, err := matchConditions(conditions, operators, set.rowGetter(row))
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			result.rows = append(result.rows, row)
+		}
+	}
+	return result, nil
+}
+
+// 嵌套循环连接：对两个中间结果做笛卡尔积，只保留满足连接条件的行，连接条件之间都是And
+func (set *resultSet) join(other *resultSet, conditions []Condition) (result *resultSet, err error) {
+	result = &resultSet{outer: set.outer}
+	result.fields = append(append(result.fields, set.fields...), other.fields...)
+	if err := result.checkConditions(conditions); err != nil {
+		return nil, err
+	}
+	operators := andOperators(len(conditions))
+	for _, left := range set.rows {
+		for _, right := range other.rows {
+			row := append(append(make([]string, 0, len(result.fields)), left...), right...)
+			matched, err := matchConditions(conditions, operators, result.rowGetter(row))
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				result.rows = append(result.rows, row)
+			}
+		}
+	}
+	return result, nil
+}
+
+// 处理JOIN：内连接只保留满足连接条件的行，外连接还要保留没有匹配上的行，缺少的一侧用空值补齐
+func (set *resultSet) joinWith(other *resultSet, join Join) (result *resultSet, err error) {
+	result = &resultSet{outer: set.outer}
+	result.fields = append(append(result.fields, set.fields...), other.fields...)
+	if err := result.checkConditions(join.Conditions); err != nil {
+		return nil, err
+	}
+	// USING (列名)：左右两边同名的列相等，记录每一对列的下标
+	var usingPairs [][2]int
+	for _, name := range join.Using {
+		left, err := set.fieldIndex(name)
+		if err != nil {
+			return nil, fmt.Errorf("at USING: %v", err)
+		}
+		right, err := other.fieldIndex(name)
+		if err != nil {
+			return nil, fmt.Errorf("at USING: %v", err)
+		}
+		usingPairs = append(usingPairs, [2]int{left, len(set.fields) + right})
+	}
+
+	rightMatched := make([]bool, len(other.rows))
+	for _, left := range set.rows {
+		leftMatched := false
+		for index, right := range other.rows {
+			row := append(append(make([]string, 0, len(result.fields)), left...), right...)
+			matched := true
+			for _, pair := range usingPairs {
+				if row[pair[0]] == NullValue || row[pair[1]] == NullValue {
+					matched = false
+					break
+				}
+				cmp, err := compareValues(row[pair[0]], row[pair[1]], result.fields[pair[0]].field.DataType)
+				if err != nil {
+					return nil, err
+				}
+				if cmp != 0 {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				matched, err = matchConditions(join.Conditions, join.ConditionOperators, result.rowGetter(row))
+				if err != nil {
+					return nil, err
+				}
+			}
+			if matched {
+				leftMatched = true
+				rightMatched[index] = true
+				result.rows = append(result.rows, row)
+			}
+		}
+		// 左外连接和全外连接：保留左边没有匹配上的行
+		if !leftMatched && (join.Type == LeftJoin || join.Type == FullJoin) {
+			row := append(append(make([]string, 0, len(result.fields)), left...), nullValues(len(other.fields))...)
+			result.rows = append(result.rows, row)
+		}
+	}
+	// 右外连接和全外连接：保留右边没有匹配上的行
+	if join.Type == RightJoin || join.Type == FullJoin {
+		for index, right := range other.rows {
+			if !rightMatched[index] {
+				row := append(append(make([]string, 0, len(result.fields)), nullValues(len(set.fields))...), right...)
+				result.rows = append(result.rows, row)
+			}
+		}
+	}
+
+	// USING的列合并为一列：左边是补齐的空值时取右边的值，右边的列不再能直接用列名访问
+	for _, pair := range usingPairs {
+		for _, row := range result.rows {
+			if row[pair[0]] == NullValue {
+				row[pair[0]] = row[pair[1]]
+			}
+		}
+		result.fields[pair[1]].hidden = true
+	}
+	return result, nil
+}
+
+// 按照给定的表达式排序，排在前面的表达式优先，比较时按照表达式的数据类型进行
+// 使用稳定排序，排序的值相同的行保持原来的顺序；空值视为最小的值
+func (set *resultSet) sort(expressions []*Expression, arrangements []string) (err error) {
+	if len(expressions) == 0 {
+		return nil
+	}
+	var dataTypes []DataType
+	for _, expression := range expressions {
+		dataType, err := expression.dataType(set)
+		if err != nil {
+			return fmt.Errorf("at ORDER BY: %v", err)
+		}
+		dataTypes = append(dataTypes, dataType)
+	}
+	// 先计算出每一行用于排序的值
+	keys := make([][]string, len(set.rows))
+	for i, row := range set.rows {
+		for _, expression := range expressions {
+			value, _, err := expression.evaluate(set.rowGetter(row))
+			if err != nil {
+				return fmt.Errorf("at ORDER BY: %v", err)
+			}
+			keys[i] = append(keys[i], value)
+		}
+	}
+	order := make([]int, len(set.rows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		for k := range expressions {
+			a, b := keys[order[i]][k], keys[order[j]][k]
+			cmp := 0
+			switch {
+			case a == NullValue && b == NullValue:
+				cmp = 0
+			case a == NullValue:
+				cmp = -1
+			case b == NullValue:
+				cmp = 1
+			default:
+				var compareErr error
+				cmp, compareErr = compareValues(a, b, dataTypes[k])
+				if compareErr != nil {
+					err = compareErr
+				}
+			}
+			if cmp == 0 {
+				continue
+			}
+			if k < len(arrangements) && arrangements[k] == "DESC" {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+	rows := make([][]string, len(set.rows))
+	for i, index := range order {
+		rows[i] = set.rows[index]
+	}
+	set.rows = rows
+	return err
+}
+
+// 分页：跳过前offset行，最多保留limit行，limit为负数时不限制
+func (set *resultSet) paginate(offset int, limit int) {
+	if offset > len(set.rows) {
+		offset = len(set.rows)
+	}
+	set.rows = set.rows[offset:]
+	if limit >= 0 && limit < len(set.rows) {
+		set.rows = set.rows[:limit]
+	}
+}
+
+// 投影：计算查询的列的表达式
+// *展开为所有的列，"表名.*"展开为该表的所有列，有别名的列在结果中使用别名作为列名
+func (set *resultSet) project(expressions []*Expression, fieldNames []string, aliases []string) (result *resultSet, err error) {
+	// 每一列要么直接取出中间结果中的某一列，要么计算表达式，不需要计算表达式时为nil
+	var indexes []int
+	var computed []*Expression
+	result = &resultSet{outer: set.outer}
+	for i, expression := range expressions {
+		fieldName := fieldNames[i]
+		switch {
+		case expression.Type == FieldExpression && fieldName == "*":
+			for index, field := range set.fields {
+				// 被USING合并掉的列不再重复出现
+				if !field.hidden {
+					indexes = append(indexes, index)
+					computed = append(computed, nil)
+					result.fields = append(result.fields, field)
+				}
+			}
+			continue
+		case expression.Type == FieldExpression && strings.HasSuffix(fieldName, ".*"):
+			tableName := strings.TrimSuffix(fieldName, ".*")
+			found := false
+			for index, field := range set.fields {
+				if field.table == tableName {
+					found = true
+					indexes = append(indexes, index)
+					computed = append(computed, nil)
+					result.fields = append(result.fields, field)
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("at SELECT: unknown table name %s", tableName)
+			}
+			continue
+		}
+		var field resultField
+		_, count := set.findField(expression.Value)
+		switch {
+		case expression.Type == FieldExpression && (count > 0 || set.outer == nil):
+			// 直接查询的列保留原来的列定义
+			index, err := set.fieldIndex(expression.Value)
+			if err != nil {
+				return nil, fmt.Errorf("at SELECT: %v", err)
+			}
+			field = set.fields[index]
+			indexes = append(indexes, index)
+			computed = append(computed, nil)
+		default:
+			// 计算出来的列不属于任何表
+			dataType, err := expression.dataType(set)
+			if err != nil {
+				return nil, fmt.Errorf("at SELECT: %v", err)
+			}
+			// 只有字符串字面值的列，例如SELECT 'CS'
+			if dataType == UnknownDataType {
+				dataType = Varchar
+			}
+			field = resultField{field: Field{Name: fieldName, DataType: dataType}}
+			indexes = append(indexes, -1)
+			computed = append(computed, expression)
+		}
+		field.field.Name = fieldName
+		if i < len(aliases) && aliases[i] != "" {
+			field.field.Name = aliases[i]
+		}
+		result.fields = append(result.fields, field)
+	}
+	for _, row := range set.rows {
+		values := make([]string, len(indexes))
+		for i, index := range indexes {
+			if computed[i] == nil {
+				values[i] = row[index]
+				continue
+			}
+			values[i], _, err = computed[i].evaluate(set.rowGetter(row))
+			if err != nil {
+				return nil, fmt.Errorf("at SELECT: %v", err)
+			}
+		}
+		result.rows = append(result.rows, values)
+	}
+	return result, nil
+}
+
+// 把中间结果转换为按列存储的元组，用于返回查询结果
+func (set *resultSet) records() (result []Record) {
+	result = []Record{}
+	for index, field := range set.fields {
+		data := []string{}
+		for _, row := range set.rows {
+			data = append(data, row[index])
+		}
+		result = append(result, Record{Field: field.field, Data: data})
+	}
+	return result
+}
+
+// 把结果转换为表的存储结构，列的定义由结果中的列得到，用于物化视图和CREATE TABLE ... AS SELECT
+func (set *resultSet) table(tableName string) (table *TableJson, err error) {
+	table = &TableJson{Name: tableName, Fields: []FieldJson{}}
+	for index, field := range set.fields {
+		// 和派生表相同，"表名.列名"形式的列只保留列名
+		if field.table != "" {
+			field.field.Name = field.field.Name[strings.LastIndex(field.field.Name, ".")+1:]
+		}
+		for _, other := range table.Fields {
+			if other.Name == field.field.Name {
+				return nil, fmt.Errorf("duplicate column name %s", other.Name)
+			}
+		}
+		data := []string{}
+		for _, row := range set.rows {
+			data = append(data, row[index])
+		}
+		table.Fields = append(table.Fields, FieldJson{
+			Name:       field.field.Name,
+			DataType:   field.field.DataType,
+			DataLength: field.field.DataLength,
+			Scale:      field.field.Scale,
+			Data:       data,
+		})
+	}
+	return table, nil
+}
+
+// 生成count-1个And，用于把count个条件全部用And连接起来
+func andOperators(count int) (operators []ConditionOperator) {
+	for i := 1; i < count; i++ {
+		operators = append(operators, And)
+	}
+	return operators
+}
+
+// 读取FROM子句中的所有表并连接起来，再用Where子句筛选
+// Where子句中没有Or时，所有条件都是And，每读入一个表就可以提前应用已经能够计算的条件，避免生成完整的笛卡尔积
+// 右外连接和全外连接会补齐左边没有匹配上的行，提前筛选会改变结果，所以这时不能提前应用条件
+// outer是外层查询当前行的valueGetter，不是子查询时为nil
+func selectFromTables(sql Sql, outer valueGetter) (set *resultSet, err error) {
+	pushDown := true
+	for _, operator := range sql.ConditionOperators {
+		if operator == Or {
+			pushDown = false
+		}
+	}
+	for _, join := range sql.Joins {
+		if join.Type == RightJoin || join.Type == FullJoin {
+			pushDown = false
+		}
+	}
+	// 先读取FROM子句中所有的表和JOIN连接的表
+	var tables, joins []*resultSet
+	for _, tableName := range sql.Tables {
+		next, err := fromResultSet(tableName, sql, outer)
+		if err != nil {
+			return nil, fmt.Errorf("at SELECT: %v", err)
+		}
+		tables = append(tables, next)
+	}
+	for _, join := range sql.Joins {
+		next, err := fromResultSet(join.Table, sql, outer)
+		if err != nil {
+			return nil, fmt.Errorf("at JOIN: %v", err)
+		}
+		joins = append(joins, next)
+	}
+	if pushDown {
+		// 条件中的列先按所有表连接之后的列检查，否则不带表名的列名在只连接了一部分表时可能只匹配先连接的表中的列
+		full := &resultSet{}
+		for _, table := range tables {
+			full.fields = append(full.fields, table.fields...)
+		}
+		for index, join := range sql.Joins {
+			for _, field := range joins[index].fields {
+				// USING合并掉的右边的列不参与不带表名的列名的匹配
+				field.hidden = field.hidden || containsString(join.Using, field.field.Name)
+				full.fields = append(full.fields, field)
+			}
+		}
+		if err := full.checkAmbiguousFields(sql.Conditions); err != nil {
+			return nil, err
+		}
+	}
+	// 没有FROM子句的查询，例如SELECT 1 + 2，在只有一行、没有列的中间结果上计算
+	if len(tables) == 0 {
+		for _, expression := range sql.FieldExpressions {
+			if expression.Type == FieldExpression && strings.HasSuffix(expression.Value, "*") {
+				return nil, fmt.Errorf("at SELECT: %s requires a FROM clause", expression.Value)
+			}
+		}
+		set = &resultSet{rows: [][]string{{}}, outer: outer}
+	}
+	applied := make([]bool, len(sql.Conditions))
+	for _, next := range tables {
+		// 找出加入这个表之后就可以计算的条件
+		var conditions []Condition
+		if pushDown {
+			joined := &resultSet{fields: next.fields}
+			if set != nil {
+				joined.fields = append(append([]resultField{}, set.fields...), next.fields...)
+			}
+			for index, condition := range sql.Conditions {
+				if !applied[index] && joined.canEvaluate(condition) {
+					conditions = append(conditions, condition)
+					applied[index] = true
+				}
+			}
+		}
+		if set == nil {
+			set, err = next.filter(conditions, andOperators(len(conditions)))
+		} else {
+			set, err = set.join(next, conditions)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// 依次处理JOIN连接的表
+	for index, join := range sql.Joins {
+		set, err = set.joinWith(joins[index], join)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if pushDown {
+		// 剩下的条件中用到了不存在的列，交给filter报错
+		var conditions []Condition
+		for index, condition := range sql.Conditions {
+			if !applied[index] {
+				conditions = append(conditions, condition)
+			}
+		}
+		return set.filter(conditions, andOperators(len(conditions)))
+	}
+	return set.filter(sql.Conditions, sql.ConditionOperators)
+}
@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 执行带有集合运算的SELECT语句
+// INTERSECT的优先级高于UNION和EXCEPT，同一优先级的集合运算从左到右计算，最后对结果排序和分页
+func setOperationResultSet(sql Sql, outer valueGetter) (set *resultSet, err error) {
+	// 先分别执行每一个SELECT语句
+	first := sql
+	first.SetOperations = nil
+	first.OrderByFields, first.OrderByArrangement, first.OrderByExpressions = nil, nil, nil
+	first.Limit, first.Offset = -1, 0
+	set, err = selectResultSet(first, outer)
+	if err != nil {
+		return nil, err
+	}
+	sets := []*resultSet{set}
+	for _, operation := range sql.SetOperations {
+		next, err := selectResultSet(*operation.Select, outer)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkSetOperationFields(set, next, operation); err != nil {
+			return nil, err
+		}
+		sets = append(sets, next)
+	}
+
+	// 先计算INTERSECT，把相邻的INTERSECT合并为一个结果
+	operands := []*resultSet{sets[0]}
+	var operations []SetOperation
+	for index, operation := range sql.SetOperations {
+		if operation.Type == Intersect {
+			last := len(operands) - 1
+			operands[last] = combineResultSets(operands[last], sets[index+1], operation)
+			continue
+		}
+		operands = append(operands, sets[index+1])
+		operations = append(operations, operation)
+	}
+	// 再从左到右计算UNION和EXCEPT
+	set = operands[0]
+	for index, operation := range operations {
+		set = combineResultSets(set, operands[index+1], operation)
+	}
+
+	// ORDER BY中的列是结果中的列，列名为第一个SELECT语句中的列名或者别名，整数n表示结果的第n列
+	orderBy := make([]*Expression, len(sql.OrderByExpressions))
+	for index, expression := range sql.OrderByExpressions {
+		orderBy[index] = expression
+		if position, ok := orderByPosition(expression); ok {
+			if position < 1 || position > len(set.fields) {
+				return nil, fmt.Errorf("at ORDER BY: position %d is not in select list", position)
+			}
+			field := set.fields[position-1]
+			name := field.field.Name
+			if field.table != "" {
+				name = field.table + "." + name
+			}
+			orderBy[index] = &Expression{Type: FieldExpression, Value: name}
+		}
+	}
+	err = set.sort(orderBy, sql.OrderByArrangement)
+	if err != nil {
+		return nil, err
+	}
+	set.paginate(sql.Offset, sql.Limit)
+	return set, nil
+}
+
+// 检查集合运算两边的列数和数据类型是否兼容，兼容时把结果的列的类型改为两边共同的类型
+// 结果的列名使用第一个SELECT语句中的列名
+func checkSetOperationFields(set *resultSet, other *resultSet, operation SetOperation) error {
+	operator := SetOperationTypeString[operation.Type]
+	if len(set.fields) != len(other.fields) {
+		return fmt.Errorf("at %s: each SELECT must have the same number of columns, got %d and %d",
+			operator, len(set.fields), len(other.fields))
+	}
+	for index := range set.fields {
+		a, b := set.fields[index].field.DataType, other.fields[index].field.DataType
+		dataType := comparisonDataType(a, b)
+		// 数值类型之间、字符串类型之间、DATE与DATETIME之间可以互相转换，其他类型必须相同
+		compatible := (isNumberType(a) && isNumberType(b)) || (isStringType(a) && isStringType(b)) ||
+			(a == Date && b == DateTime) || (a == DateTime && b == Date)
+		if a != b && a != UnknownDataType && b != UnknownDataType && !compatible {
+			return fmt.Errorf("at %s: column %d has incompatible types %s and %s",
+				operator, index+1, DataTypeString[a], DataTypeString[b])
+		}
+		set.fields[index].field.DataType = dataType
+	}
+	return nil
+}
+
+// 对两个中间结果做集合运算，除了ALL之外都会去掉重复的行
+func combineResultSets(set *resultSet, other *resultSet, operation SetOperation) (result *resultSet) {
+	result = &resultSet{fields: set.fields, outer: set.outer}
+	switch operation.Type {
+	case Union:
+		result.rows = append(append(result.rows, set.rows...), other.rows...)
+	case Intersect, Except:
+		// 统计右边每一行出现的次数，左边的行每匹配一次就消耗一次
+		counts := map[string]int{}
+		for _, row := range other.rows {
+			counts[rowKey(row, other.fields)]++
+		}
+		for _, row := range set.rows {
+			key := rowKey(row, set.fields)
+			matched := counts[key] > 0
+			if matched && operation.All {
+				counts[key]--
+			}
+			if matched == (operation.Type == Intersect) {
+				result.rows = append(result.rows, row)
+			}
+		}
+	}
+	if !operation.All {
+		result.distinct()
+	}
+	return result
+}
+
+// 去掉重复的行，保留每一行第一次出现的位置
+func (set *resultSet) distinct() {
+	seen := map[string]bool{}
+	var rows [][]string
+	for _, row := range set.rows {
+		key := rowKey(row, set.fields)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		rows = append(rows, row)
+	}
+	set.rows = rows
+}
+
+// 把一行数据转换为用于比较整行是否相同的字符串，数值按数值比较，例如85与85.0相同
+func rowKey(row []string, fields []resultField) string {
+	values := make([]string, len(row))
+	for index, value := range row {
+		dataType := fields[index].field.DataType
+		if value != NullValue && isNumberType(dataType) {
+			if number, err := parseDecimal(value); err == nil {
+				value = number.RatString()
+			}
+		}
+		// CHAR末尾补齐的空格不参与比较
+		if dataType == Char {
+			value = strings.TrimRight(value, " ")
+		}
+		values[index] = value
+	}
+	return joinValues(values)
+}
+
+// 把多个值连接成一个字符串，用于判断多个值组成的元组是否相同
+// 每个值都加上引号，这样空值与空字符串、值中的分隔符都不会混淆
+func joinValues(values []string) string {
+	quoted := make([]string, len(values))
+	for index, value := range values {
+		quoted[index] = strconv.Quote(value)
+	}
+	return strings.Join(quoted, ",")
+}
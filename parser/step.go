@@ -22,20 +22,41 @@ package parser
 type step int
 
 const (
-	stepBeginning                             step = iota // "SELECT" / "UPDATE"
+	stepBeginning                             step = iota // "SELECT" / "SELECT DISTINCT" / "UPDATE"
 	stepSelectField                                       // 'Sno' => stepSelectComma(多字段) / stepSelectFrom(单字段)
 	stepSelectComma                                       // "," => stepSelectField
 	stepSelectFrom                                        // "FROM" => stepSelectFromTable
-	stepSelectFromTable                                   // 'Student' => stepSelectFromTableComma(多表) / stepWhere(单表)
+	stepSelectFromTable                                   // 'Student' => stepSelectFromTableComma(多表) / stepSelectJoin(JOIN) / stepWhere(单表)
 	stepSelectFromTableComma                              // "," => stepSelectFromTable
-	stepSelectGroupBy                                     // "GROUP BY" => TODO GROUP BY状态实现
-	stepSelectHaving                                      // "HAVING" => TODO HAVING状态实现
-	stepSelectOrderBy                                     // "ORDER BY" => TODO ORDER BY状态实现
+	stepSelectJoin                                        // "LEFT JOIN" => stepSelectJoinTable
+	stepSelectJoinTable                                   // 'SC' => stepSelectJoinOn / stepSelectJoinUsing / stepWhere(交叉连接)
+	stepSelectJoinOn                                      // "ON" => stepWhereField，条件放入当前的连接中
+	stepSelectJoinUsing                                   // "USING" => stepSelectJoinUsingOpeningParens
+	stepSelectJoinUsingOpeningParens                      // "(" => stepSelectJoinUsingField
+	stepSelectJoinUsingField                              // 'Sno' => stepSelectJoinUsingCommaOrClosingParens
+	stepSelectJoinUsingCommaOrClosingParens               // "," / ")" => stepSelectJoinUsingField(多字段) / stepSelectJoin / stepWhere
+	stepSelectGroupBy                                     // "GROUP BY" => stepSelectGroupByField
+	stepSelectGroupByField                                // 'Sno' => stepSelectGroupByComma / stepSelectHaving / stepSelectOrderBy
+	stepSelectGroupByComma                                // "," => stepSelectGroupByField
+	stepSelectHaving                                      // "HAVING" => stepWhereField，条件放入HAVING子句中
+	stepSelectOrderBy                                     // "ORDER BY" => stepSelectOrderByField
+	stepSelectOrderByField                                // 'Sage' => stepSelectOrderByAscOrDesc / stepSelectOrderByComma
+	stepSelectOrderByAscOrDesc                            // "ASC" / "DESC" => stepSelectOrderByComma
+	stepSelectOrderByComma                                // "," => stepSelectOrderByField
+	stepSelectLimit                                       // "LIMIT" => stepSelectLimitValue
+	stepSelectLimitValue                                  // '10' => stepSelectOffset
+	stepSelectOffset                                      // "OFFSET" => stepSelectOffsetValue
+	stepSelectOffsetValue                                 // '20' => stepSelectFetch / stepSelectLimit，后面可以有"ROWS"
+	stepSelectFetch                                       // "FETCH FIRST" / "FETCH NEXT" => stepSelectFetchValue
+	stepSelectFetchValue                                  // '10' => stepSelectFetchRowsOnly，省略行数时为1
+	stepSelectFetchRowsOnly                               // "ROWS ONLY" => 语句结束
+	stepSelectSetOperation                                // "UNION" / "UNION ALL" / "INTERSECT" / "EXCEPT" => 其余部分作为另一个SELECT语句解析
+	stepSelectEnd                                         // 已经读到语句末尾，最后一个子句是完整的
 	stepInsertTable                                       // 'SC' => stepInsertFieldsOpeningParens
-	stepInsertFieldsOpeningParens                         // "(" => stepInsertFields
+	stepInsertFieldsOpeningParens                         // "(" => stepInsertFields / "SELECT" / "VALUES" => stepInsertValue(省略列名)
 	stepInsertFields                                      // 'Sno' => stepInsertFieldsCommaOrClosingParens
 	stepInsertFieldsCommaOrClosingParens                  // "," / ")" => stepInsertFields(多字段) / stepInsertValuesRWord(单字段)
-	stepInsertValues                                      // "VALUES" => stepInsertValuesOpeningParens
+	stepInsertValues                                      // "VALUES" => stepInsertValuesOpeningParens / "SELECT" => 其余部分作为SELECT语句解析
 	stepInsertValuesOpeningParens                         // "(" => stepInsertValues
 	stepInsertValue                                       // '201215128' => stepInsertValuesCommaOrClosingParens
 	stepInsertValuesCommaOrClosingParens                  // "," / ")" => stepInsertValues(多字段) / stepInsertFieldsOpeningParens(单字段)
@@ -48,9 +69,9 @@ const (
 	stepUpdateComma                                       // "," / "WHERE" => stepUpdateField
 	stepDeleteFromTable                                   // 'Student' => stepWhere
 	stepWhere                                             // "WHERE" => stepWhereField
-	stepWhereField                                        // 'Sdept' => stepWhereOperator
-	stepWhereOperator                                     // "=" => stepWhereValue
-	stepWhereValue                                        // 'CS' => stepWhereAnd
+	stepWhereField                                        // 'Sdept' => stepWhereOperator / "NOT", "(" => stepWhereField，括号中的条件放入一组中
+	stepWhereOperator                                     // "=" => stepWhereValue / "IS NULL", "IS NOT NULL" => stepWhereAnd / Or
+	stepWhereValue                                        // 'CS' => stepWhereAnd，后面的")"结束括号中的一组条件
 	stepWhereAnd                                          // "AND" => stepWhereField
 	stepWhereOr                                           // "OR" => stepWhereField
 	stepWhereBetween                                      // "BETWEEN" => stepWhereBetweenValue
@@ -63,29 +84,30 @@ const (
 	stepWhereInOpeningParens                              // "(" => stepWhereInValue
 	stepWhereInValue                                      // 'CS' => stepWhereInCommaOrClosingParens
 	stepWhereInCommaOrClosingParens                       // ",", ")" => stepWhereInValue / and or等
-	stepCreateTableName                                   // 'Student' => stepCreateTableOpeningParens
+	stepCreateTableName                                   // 'Student' => stepCreateTableOpeningParens / stepCreateTableAs
+	stepCreateTableAs                                     // "AS" => 其余部分作为SELECT语句解析
 	stepCreateTableOpeningParens                          // "(" => stepCreateTableField
 	stepCreateTableField                                  // 'Sno' => stepCreateTableFieldType
 	stepCreateTableFieldType                              // "CHAR" => stepCreateTableFieldOpeningParens(有长度) / stepCreateTableComma(无长度) / 约束
 	stepCreateTableFieldOpeningParens                     // "(" => stepCreateTableFieldLength
-	stepCreateTableFieldLength                            // '9' => stepCreateTableFieldClosingParens
+	stepCreateTableFieldLength                            // '9' => stepCreateTableFieldClosingParens / '10' ',' '2'(DECIMAL的精度和小数位数) => stepCreateTableFieldClosingParens
 	stepCreateTableFieldClosingParens                     // ")" => stepCreateTableComma / stepCreateTableClosingParens / stepCreateTableConstraintType
-	stepCreateTableComma                                  // "," => stepCreateTableField(多字段) / stepCreateTableClosingParens(单字段) / 主键、外键约束
-	stepCreateTableConstraintType                         // "NOT NULL" => stepCreateTableComma / stepCheck(约束类型为Check) / stepCreateTableClosingParens
+	stepCreateTableComma                                  // "," => stepCreateTableField(多字段) / stepCreateTableClosingParens(单字段) / 主键、唯一、外键、Check约束
+	stepCreateTableConstraintType                         // "NOT NULL" => stepCreateTableComma / stepCheck(约束类型为Check) / stepCreateTableClosingParens / stepCreateTableConstraintType(多个约束)
 	stepCreateTableClosingParens                          // ")" => stepCreateTableOpeningParens
 	stepCheck                                             // "CHECK" => stepCheckOpeningParens
 	stepCheckOpeningParens                                // "(" => stepCheckField
 	stepCheckField                                        // 'Grade' => stepCheckOperator
-	stepCheckOperator                                     // '>=' => stepCheckValue
+	stepCheckOperator                                     // '>=' => stepCheckValue / "IS NULL", "IS NOT NULL" => stepCheckClosingParens / stepCheckAnd / Or
 	stepCheckValue                                        // '0' => stepCheckClosingParens / stepCheckAnd / Or
-	stepCheckClosingParens                                // ")" => stepCreateTableComma
+	stepCheckClosingParens                                // ")" => stepCreateTableComma / stepCreateTableClosingParens / 结束(ALTER TABLE)
 	stepCheckAnd                                          // "AND" => stepCheckField
 	stepCheckOr                                           // "OR" => stepCheckField
 	stepCheckIn                                           // "IN" => stepCheckInOpeningParens
 	stepCheckInOpeningParens                              // "(" => stepCheckInValue
 	stepCheckInValue                                      // '男' => stepCheckInCommaOrClosingParens
 	stepCheckInCommaOrClosingParens                       // "," / ")" => stepCheckInValue / stepCheckClosingParens
-	stepPrimaryKey                                        // "PRIMARY KEY" => stepPrimaryKeyOpeningParens
+	stepPrimaryKey                                        // "PRIMARY KEY" / "UNIQUE" => stepPrimaryKeyOpeningParens
 	stepPrimaryKeyOpeningParens                           // "(" => stepPrimaryKeyField
 	stepPrimaryKeyField                                   // 'Sno' => stepPrimaryKeyCommaOrClosingParens
 	stepPrimaryKeyCommaOrClosingParens                    // "," / ")" => stepPrimaryKeyField(多字段) / stepCreateTableComma(单字段)
@@ -97,13 +119,30 @@ const (
 	stepForeignKeyReferenceTable                          // 'Course' => stepForeignKeyReferenceFieldOpeningParens
 	stepForeignKeyReferenceFieldOpeningParens             // "(" => stepForeignKeyReferenceField
 	stepForeignKeyReferenceField                          // 'Cno' => stepForeignKeyReferenceFieldClosingParens
-	stepForeignKeyReferenceFieldClosingParens             // ")" => stepCreateTableComma / stepCreateTableClosingParens
+	stepForeignKeyReferenceFieldClosingParens             // ")" => stepCreateTableComma / stepCreateTableClosingParens / stepForeignKeyAction
+	stepForeignKeyAction                                  // "ON DELETE" / "ON UPDATE" 'CASCADE' => stepForeignKeyAction / stepCreateTableComma / stepCreateTableClosingParens / 结束(ALTER TABLE)
 	stepCreateViewName                                    // 'IS_STUDENT' => stepCreateViewOpeningParens(有列名) / stepCreateViewAs(无列名)
 	stepCreateViewOpeningParens                           // "(" => stepCreateViewField
 	stepCreateViewField                                   // 'Sno' => stepCreateViewCommaOrClosingParens
 	stepCreateViewCommaOrClosingParens                    // "," / ")" => stepCreateViewField(多字段) / stepCreateViewAs(单字段)
 	stepCreateViewAs                                      // "AS" => stepCreateViewSelect
 	stepCreateViewSelect                                  // "SELECT" => stepCreateView。注：整个SELECT语句存入文件
+	stepRefreshViewName                                   // 'S_G' => 结束
+	stepDropName                                          // 'Student' => stepDropCommaOrBehavior
+	stepDropCommaOrBehavior                               // "," / "ON" / "CASCADE" / "RESTRICT" => stepDropName / stepDropIndexTable(DROP INDEX) / 结束
+	stepDropIndexTable                                    // 'Student' => stepDropCommaOrBehavior
+	stepAlterTableName                                    // 'Student' => stepAlterTableAction
+	stepAlterTableAction                                  // "ADD COLUMN" / "DROP COLUMN" / "RENAME COLUMN" / "ALTER COLUMN" / "ADD CONSTRAINT" / "DROP CONSTRAINT" => stepCreateTableField / stepAlterTableColumn / stepAlterTableConstraintName
+	stepAlterTableColumn                                  // 'Sage' => 结束(DROP) / "TO" stepAlterTableNewColumn(RENAME) / "TYPE" stepCreateTableFieldType(ALTER)
+	stepAlterTableNewColumn                               // 'Sage2' => 结束
+	stepAlterTableConstraintName                          // 'uq_sname' => stepAlterTableConstraintType(ADD) / 结束(DROP)
+	stepAlterTableConstraintType                          // "UNIQUE" / "PRIMARY KEY" / "FOREIGN KEY" => stepAlterTableConstraintOpeningParens / "CHECK" => stepCheckOpeningParens
+	stepAlterTableConstraintOpeningParens                 // "(" => stepAlterTableConstraintField
+	stepAlterTableConstraintField                         // 'Sname' => stepAlterConstraintCommaOrClosingParens
+	stepAlterConstraintCommaOrClosingParens               // "," / ")" => stepAlterTableConstraintField(多字段) / stepAlterTableReferences(外键) / 结束
+	stepAlterTableReferences                              // "REFERENCES" => stepAlterTableReferenceTable
+	stepAlterTableReferenceTable                          // 'Course' "(" => stepAlterTableReferenceField
+	stepAlterTableReferenceField                          // 'Cno' ")" => stepForeignKeyAction / 结束
 	stepCreateIndexName                                   // 'index_name' => stepCreateIndexOn
 	stepCreateIndexOn                                     // "ON" => stepCreateIndexTableName
 	stepCreateIndexTableName                              // 'table_name' => stepCreateIndexOpeningParens
@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 解析一个单独的SELECT语句，用于子查询、集合运算和视图
+func parseSelect(sql string) (parsedSql Sql, err error) {
+	parsedSql, err = (&parser{
+		sql:      strings.TrimSpace(sql),
+		position: 0,
+		query:    Sql{},
+		step:     stepBeginning,
+		err:      nil,
+	}).doParse()
+	if err != nil {
+		return parsedSql, err
+	}
+	if parsedSql.Type != Select {
+		return parsedSql, fmt.Errorf("expected SELECT")
+	}
+	return parsedSql, nil
+}
+
+// 判断当前位置是否是括号中的子查询：左括号后面紧跟着SELECT
+func (p *parser) peekSubquery() bool {
+	if p.peek() != "(" {
+		return false
+	}
+	position := p.position
+	p.pop()
+	isSelect := strings.ToUpper(p.peek()) == "SELECT"
+	p.position = position
+	return isSelect
+}
+
+// 弹出括号中的子查询并解析，当前位置是子查询的左括号
+func (p *parser) popSubquery() (subquery *Sql, err error) {
+	if !p.peekSubquery() {
+		return nil, fmt.Errorf("expected subquery '(SELECT ...)'")
+	}
+	start := p.position + 1
+	depth := 0
+	for i := p.position; i < len(p.sql); i++ {
+		switch p.sql[i] {
+		case '\'':
+			// 跳过字符串，字符串中的括号不算
+			for i++; i < len(p.sql) && !(p.sql[i] == '\'' && p.sql[i-1] != '\\'); i++ {
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			// 找到了与左括号匹配的右括号，括号中的部分作为一个单独的SELECT语句解析
+			sql, err := parseSelect(p.sql[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("at subquery: %v", err)
+			}
+			p.position = i + 1
+			p.popWhitespace()
+			return &sql, nil
+		}
+	}
+	return nil, fmt.Errorf("at subquery: expected closing parens ')'")
+}
+
+// 弹出FROM或者JOIN后面的表：表名 [[AS] 别名]，或者派生表：(SELECT ...) [AS] 别名
+// 有别名时返回别名，查询中只能用别名引用这个表
+func (p *parser) popFromTable() (tableName string, err error) {
+	if !p.peekSubquery() {
+		tableName = p.peek()
+		if !isIdentifier(tableName) {
+			return "", fmt.Errorf("at SELECT: expected table name")
+		}
+		p.pop()
+		alias, err := p.popTableAlias(false)
+		if err != nil || alias == "" {
+			return tableName, err
+		}
+		if p.query.TableAliases == nil {
+			p.query.TableAliases = map[string]string{}
+		}
+		p.query.TableAliases[alias] = tableName
+		return alias, nil
+	}
+	subquery, err := p.popSubquery()
+	if err != nil {
+		return "", err
+	}
+	// 派生表必须有别名
+	tableName, err = p.popTableAlias(true)
+	if err != nil {
+		return "", err
+	}
+	if p.query.DerivedTables == nil {
+		p.query.DerivedTables = map[string]*Sql{}
+	}
+	p.query.DerivedTables[tableName] = subquery
+	return tableName, nil
+}
+
+// 弹出表的别名：[AS] 别名，没有别名时返回空字符串
+func (p *parser) popTableAlias(required bool) (alias string, err error) {
+	hasAs := strings.ToUpper(p.peek()) == "AS"
+	if hasAs {
+		p.pop()
+	}
+	alias = p.peek()
+	// 关键字不是标识符，例如WHERE、JOIN，说明没有别名
+	if !isIdentifier(alias) || strings.Contains(alias, ".") {
+		if hasAs || required {
+			return "", fmt.Errorf("at SELECT: expected table alias")
+		}
+		return "", nil
+	}
+	return p.pop(), nil
+}
+
+// 执行只返回一列的子查询，返回这一列的所有值和数据类型，用于IN和标量子查询
+// outer是外层查询当前行的valueGetter，相关子查询通过它读取外层查询的列
+func subqueryValues(sql Sql, outer valueGetter) (values []string, dataType DataType, err error) {
+	set, err := selectResultSet(sql, outer)
+	if err != nil {
+		return nil, UnknownDataType, err
+	}
+	if len(set.fields) != 1 {
+		return nil, UnknownDataType, fmt.Errorf("at subquery: subquery must return exactly one column, got %d", len(set.fields))
+	}
+	for _, row := range set.rows {
+		values = append(values, row[0])
+	}
+	return values, set.fields[0].field.DataType, nil
+}
+
+// 读取FROM子句中的一个表：派生表先执行子查询，视图先执行视图的SELECT语句，其他的表读取表文件
+// tableName是表名或者别名
+func fromResultSet(tableName string, sql Sql, outer valueGetter) (set *resultSet, err error) {
+	subquery, ok := sql.DerivedTables[tableName]
+	if ok {
+		set, err = selectResultSet(*subquery, outer)
+	} else {
+		name := tableName
+		if realName, ok := sql.TableAliases[tableName]; ok {
+			name = realName
+		}
+		table, err := readTableJson(name)
+		if err == nil {
+			set = tableResultSet(table)
+			// 有别名的表中的列属于别名
+			for index := range set.fields {
+				set.fields[index].table = tableName
+			}
+			set.outer = outer
+			return set, nil
+		}
+		// 没有这个表时查找同名的视图
+		view, viewErr := readView(name)
+		if viewErr != nil {
+			return nil, viewErr
+		}
+		if view == nil {
+			return nil, err
+		}
+		set, err = viewResultSet(view)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 派生表和视图的列都属于派生表或者视图，"表名.列名"形式的列只保留列名
+	for index := range set.fields {
+		field := &set.fields[index]
+		if field.table != "" {
+			if dot := strings.LastIndex(field.field.Name, "."); dot >= 0 {
+				field.field.Name = field.field.Name[dot+1:]
+			}
+		}
+		field.table = tableName
+		field.hidden = false
+	}
+	set.outer = outer
+	return set, nil
+}
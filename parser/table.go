@@ -14,7 +14,8 @@ type Table struct {
 type Field struct {
 	Name                     string              // 列名
 	DataType                 DataType            // 该列的数据类型
-	DataLength               int                 // 该列数据的长度
+	DataLength               int                 // 该列数据的长度，DECIMAL为精度
+	Scale                    int                 // DECIMAL的小数位数
 	Constraint               []Constraint        // 列的约束条件——约束类型和具体的约束条件，是一个数组
 	CheckConditions          []Condition         // Check约束的条件
 	CheckConditionsOperator  []ConditionOperator // Check约束的连接运算符，只可能为And或者Or
@@ -25,6 +26,22 @@ type Field struct {
 	ForeignKeyFlag           bool                // 当前正在定义这个字段的外键，一般为false，在Create Table的ForeignKey语句中使用
 	ForeignKeyReferenceTable string              // 外键被参照表
 	ForeignKeyReferenceField string              // 外键被参照列
+	OnDelete                 ReferentialAction   // 删除被参照的行时外键的参照动作
+	OnUpdate                 ReferentialAction   // 更新被参照的行时外键的参照动作
+	Default                  string              // 默认值的表达式，为空时没有默认值
+}
+
+// 表级约束：有名称的约束，可以用ALTER TABLE ADD CONSTRAINT增加，用DROP CONSTRAINT删除
+type TableConstraint struct {
+	Name               string              // 约束名
+	ConstraintType     ConstraintType      // 约束类型：唯一、主键、外键或者Check
+	Fields             []string            // 约束作用的列
+	ReferenceTable     string              // 外键被参照表
+	ReferenceField     string              // 外键被参照列
+	Conditions         []Condition         // Check约束的条件
+	ConditionOperators []ConditionOperator // Check约束的连接运算符，只可能为And或者Or
+	OnDelete           ReferentialAction   // 删除被参照的行时外键的参照动作
+	OnUpdate           ReferentialAction   // 更新被参照的行时外键的参照动作
 }
 
 // 元组的定义，用于返回
@@ -58,3 +75,37 @@ const (
 	// 没有约束
 	Default
 )
+
+var ConstraintTypeString = []string{
+	"UNKNOWN",
+	"NOT NULL",
+	"UNIQUE",
+	"PRIMARY KEY",
+	"CHECK",
+	"FOREIGN KEY",
+	"DEFAULT",
+}
+
+// 外键的参照动作：删除或者更新被参照的行时，怎样处理参照这一行的行
+type ReferentialAction int
+
+const (
+	// 被参照的行仍然被参照时不能删除或者更新，是默认的参照动作
+	NoAction ReferentialAction = iota
+	// 和NoAction相同，被参照的行仍然被参照时不能删除或者更新
+	Restrict
+	// 级联删除参照这一行的行，或者把参照这一行的行中的外键改为新的值
+	Cascade
+	// 把参照这一行的行中的外键设为空值
+	SetNull
+	// 把参照这一行的行中的外键设为默认值
+	SetDefault
+)
+
+var ReferentialActionString = []string{
+	"NO ACTION",
+	"RESTRICT",
+	"CASCADE",
+	"SET NULL",
+	"SET DEFAULT",
+}
@@ -0,0 +1,462 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 视图文件中保存的视图定义：CREATE [MATERIALIZED] VIEW 视图名 [(列名, ...)] AS SELECT ...
+func viewDefinition(sql Sql) string {
+	definition := "CREATE VIEW " + sql.Tables[0]
+	if sql.Type == CreateMaterializedView {
+		definition = "CREATE MATERIALIZED VIEW " + sql.Tables[0]
+	}
+	if len(sql.Fields) > 0 {
+		definition += " (" + strings.Join(sql.Fields, ", ") + ")"
+	}
+	definition += " AS " + sql.ViewSelect
+	if sql.WithCheckOption {
+		definition += " WITH CHECK OPTION"
+	}
+	return definition
+}
+
+// 执行视图的SELECT语句得到视图中的数据，视图定义中有列名时用列名重命名结果中的列
+func viewResultSet(view *Sql) (set *resultSet, err error) {
+	viewName := view.Tables[0]
+	query, err := parseSelect(view.ViewSelect)
+	if err != nil {
+		return nil, fmt.Errorf("at view %s: %v", viewName, err)
+	}
+	// 视图的定义与外层查询无关，不能引用外层查询的列
+	set, err = selectResultSet(query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("at view %s: %v", viewName, err)
+	}
+	if len(view.Fields) == 0 {
+		return set, nil
+	}
+	if len(view.Fields) != len(set.fields) {
+		return nil, fmt.Errorf("at view %s: view has %d columns but SELECT returns %d",
+			viewName, len(view.Fields), len(set.fields))
+	}
+	for index := range set.fields {
+		set.fields[index].field.Name = view.Fields[index]
+	}
+	return set, nil
+}
+
+// 计算物化视图中的数据，转换为表的存储结构，表中同时保存物化视图的定义用于重新计算
+func materializeView(sql Sql) (table *TableJson, err error) {
+	set, err := viewResultSet(&sql)
+	if err != nil {
+		return nil, err
+	}
+	table, err = set.table(sql.Tables[0])
+	if err != nil {
+		return nil, fmt.Errorf("at materialized view %s: %v", sql.Tables[0], err)
+	}
+	table.Definition = viewDefinition(sql)
+	return table, nil
+}
+
+// 找出SELECT语句引用的所有表和视图，包括子查询、派生表和集合运算中引用的
+func referencedTables(sql Sql) (tables []string) {
+	var add func(tableName string, sql Sql)
+	var walk func(sql Sql)
+	var walkExpression func(expr *Expression)
+	// FROM或者JOIN后面的表，可能是别名或者派生表
+	add = func(tableName string, sql Sql) {
+		if subquery, ok := sql.DerivedTables[tableName]; ok {
+			walk(*subquery)
+			return
+		}
+		if realName, ok := sql.TableAliases[tableName]; ok {
+			tableName = realName
+		}
+		if !containsString(tables, tableName) {
+			tables = append(tables, tableName)
+		}
+	}
+	walkExpression = func(expr *Expression) {
+		if expr == nil {
+			return
+		}
+		if expr.Subquery != nil {
+			walk(*expr.Subquery)
+		}
+		walkExpression(expr.Left)
+		walkExpression(expr.Right)
+		for _, argument := range expr.Arguments {
+			walkExpression(argument)
+		}
+	}
+	var walkConditions func(conditions []Condition)
+	walkConditions = func(conditions []Condition) {
+		for _, condition := range conditions {
+			walkConditions(condition.Group)
+			if condition.Subquery != nil {
+				walk(*condition.Subquery)
+			}
+			walkExpression(condition.Expression1)
+			walkExpression(condition.Expression2)
+		}
+	}
+	walk = func(sql Sql) {
+		for _, tableName := range sql.Tables {
+			add(tableName, sql)
+		}
+		for _, join := range sql.Joins {
+			add(join.Table, sql)
+			walkConditions(join.Conditions)
+		}
+		walkConditions(sql.Conditions)
+		walkConditions(sql.HavingConditions)
+		for _, expr := range sql.FieldExpressions {
+			walkExpression(expr)
+		}
+		for _, expr := range sql.OrderByExpressions {
+			walkExpression(expr)
+		}
+		for _, operation := range sql.SetOperations {
+			walk(*operation.Select)
+		}
+	}
+	walk(sql)
+	return tables
+}
+
+// 判断视图是否直接或者间接地引用了某个表或者视图
+func viewReferences(query Sql, name string, visited map[string]bool) (bool, error) {
+	for _, tableName := range referencedTables(query) {
+		if tableName == name {
+			return true, nil
+		}
+		if visited[tableName] {
+			continue
+		}
+		visited[tableName] = true
+		view, err := readView(tableName)
+		if err != nil {
+			return false, err
+		}
+		if view == nil {
+			continue
+		}
+		viewQuery, err := parseSelect(view.ViewSelect)
+		if err != nil {
+			return false, err
+		}
+		found, err := viewReferences(viewQuery, name, visited)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// 可更新视图：只引用一个表、没有聚集函数的视图，对它的INSERT、UPDATE、DELETE改写为对基本表的操作
+type updatableView struct {
+	Name               string                 // 视图名
+	Table              string                 // 视图最终引用的基本表
+	Columns            []string               // 视图的列名
+	Expressions        map[string]*Expression // 视图的列名到用基本表的列表示的表达式
+	Conditions         []Condition            // 视图以及它引用的视图的条件，用基本表的列表示
+	ConditionOperators []ConditionOperator    // 视图的条件之间的连接符
+	CheckView          string                 // 带有WITH CHECK OPTION的视图，没有时为空字符串
+	CheckConditions    []Condition            // 插入和更新的行必须满足的条件
+	CheckOperators     []ConditionOperator    // 必须满足的条件之间的连接符
+}
+
+// 读取视图并展开为对基本表的引用，不存在这个名称的视图时返回nil
+func readUpdatableView(viewName string) (view *updatableView, err error) {
+	definition, err := readView(viewName)
+	if err != nil || definition == nil {
+		return nil, err
+	}
+	return newUpdatableView(definition)
+}
+
+// 展开视图的定义，视图引用的视图也会展开，直到基本表
+func newUpdatableView(definition *Sql) (view *updatableView, err error) {
+	view = &updatableView{Name: definition.Tables[0]}
+	query, err := parseSelect(definition.ViewSelect)
+	if err != nil {
+		return nil, fmt.Errorf("at view %s: %v", view.Name, err)
+	}
+	// 只有单表、没有分组和聚集函数的视图才能更新
+	// 有ORDER BY、LIMIT或者OFFSET的视图只包含基本表中的一部分行，改写为对基本表的语句时无法保留，也不能更新
+	updatable := len(query.Tables) == 1 && len(query.Joins) == 0 && len(query.DerivedTables) == 0 &&
+		len(query.SetOperations) == 0 && len(query.GroupByFields) == 0 && len(query.HavingConditions) == 0 &&
+		!query.Distinct && len(query.OrderByFields) == 0 && query.Limit < 0 && query.Offset == 0
+	for _, expression := range query.FieldExpressions {
+		if len(expression.aggregates()) > 0 {
+			updatable = false
+		}
+	}
+	if !updatable {
+		return nil, fmt.Errorf("at view %s: view is not updatable", view.Name)
+	}
+
+	// 视图引用的表或者视图，先展开视图引用的视图
+	from := query.Tables[0]
+	inner, err := readUpdatableView(from)
+	if realName, ok := query.TableAliases[from]; ok {
+		inner, err = readUpdatableView(realName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if inner == nil {
+		// 引用的是基本表
+		name := from
+		if realName, ok := query.TableAliases[from]; ok {
+			name = realName
+		}
+		table, err := readTableJson(name)
+		if err != nil {
+			return nil, fmt.Errorf("at view %s: %v", view.Name, err)
+		}
+		// 物化视图只能通过REFRESH MATERIALIZED VIEW更新，引用它的视图也不能更新
+		if table.Definition != "" {
+			return nil, fmt.Errorf("at view %s: materialized view %s is read-only", view.Name, table.Name)
+		}
+		inner = &updatableView{Table: table.Name, Expressions: map[string]*Expression{}}
+		for _, field := range table.Fields {
+			inner.Columns = append(inner.Columns, field.Name)
+			inner.Expressions[field.Name] = &Expression{Type: FieldExpression, Value: field.Name}
+		}
+	}
+	view.Table = inner.Table
+	scope := inner.scope(from)
+
+	// 视图的条件用基本表的列表示，再和引用的视图的条件合并
+	conditions, err := substituteConditions(query.Conditions, scope)
+	if err != nil {
+		return nil, fmt.Errorf("at view %s: %v", view.Name, err)
+	}
+	view.Conditions, view.ConditionOperators = conjoinConditions(inner.Conditions, inner.ConditionOperators,
+		conditions, query.ConditionOperators)
+	// WITH CHECK OPTION同时检查引用的视图的条件，没有时沿用引用的视图的检查
+	if definition.WithCheckOption {
+		view.CheckView, view.CheckConditions, view.CheckOperators = view.Name, view.Conditions, view.ConditionOperators
+	} else {
+		view.CheckView, view.CheckConditions, view.CheckOperators = inner.CheckView, inner.CheckConditions, inner.CheckOperators
+	}
+
+	// 视图的列，*展开为引用的表或者视图的全部列
+	var expressions []*Expression
+	for index, expression := range query.FieldExpressions {
+		if expression.Type == FieldExpression && (expression.Value == "*" || expression.Value == from+".*") {
+			for _, column := range inner.Columns {
+				view.Columns = append(view.Columns, column)
+				expressions = append(expressions, inner.Expressions[column])
+			}
+			continue
+		}
+		name := query.Aliases[index]
+		if name == "" {
+			name = query.Fields[index]
+			if expression.Type == FieldExpression {
+				name = expression.Value[strings.LastIndex(expression.Value, ".")+1:]
+			}
+		}
+		substituted, err := expression.substituteFields(scope)
+		if err != nil {
+			return nil, fmt.Errorf("at view %s: %v", view.Name, err)
+		}
+		view.Columns = append(view.Columns, name)
+		expressions = append(expressions, substituted)
+	}
+	// 视图定义中的列名
+	if len(definition.Fields) > 0 {
+		if len(definition.Fields) != len(view.Columns) {
+			return nil, fmt.Errorf("at view %s: view has %d columns but SELECT returns %d",
+				view.Name, len(definition.Fields), len(view.Columns))
+		}
+		view.Columns = definition.Fields
+	}
+	view.Expressions = map[string]*Expression{}
+	for index, column := range view.Columns {
+		view.Expressions[column] = expressions[index]
+	}
+	return view, nil
+}
+
+// 视图的列名到表达式的映射，列名也可以带上表名或者别名
+func (view *updatableView) scope(qualifier string) map[string]*Expression {
+	scope := map[string]*Expression{}
+	for column, expression := range view.Expressions {
+		scope[column] = expression
+		scope[qualifier+"."+column] = expression
+	}
+	return scope
+}
+
+// 把对视图的INSERT、UPDATE、DELETE语句改写为对基本表的语句，操作的不是视图时原样返回
+func rewriteViewStatement(sql Sql) (rewritten Sql, view *updatableView, err error) {
+	operation := strings.ToUpper(TypeString[sql.Type])
+	if fileName, err := getObjectFileByName(sql.Tables[0] + ".json"); err != nil || fileName != "" {
+		if err != nil {
+			return sql, nil, err
+		}
+		// 物化视图只能通过REFRESH MATERIALIZED VIEW更新
+		if table, err := readTableJson(sql.Tables[0]); err == nil && table.Definition != "" {
+			return sql, nil, fmt.Errorf("at %s: materialized view %s is read-only", operation, sql.Tables[0])
+		}
+		return sql, nil, nil
+	}
+	view, err = readUpdatableView(sql.Tables[0])
+	if err != nil {
+		return sql, nil, fmt.Errorf("at %s: %v", operation, err)
+	}
+	// 既不是表也不是视图，由后面的处理报错
+	if view == nil {
+		return sql, nil, nil
+	}
+	rewritten = sql
+	rewritten.Tables = []string{view.Table}
+	scope := view.scope(view.Name)
+	// 插入或者更新的列必须是直接引用基本表的列
+	baseField := func(column string) (string, error) {
+		expression, ok := scope[column]
+		if !ok {
+			return "", fmt.Errorf("at %s: unknown field %s in view %s", operation, column, view.Name)
+		}
+		if expression.Type != FieldExpression {
+			return "", fmt.Errorf("at %s: field %s in view %s is not updatable", operation, column, view.Name)
+		}
+		return expression.Value, nil
+	}
+	switch sql.Type {
+	case Insert:
+		rewritten.Fields = nil
+		for _, column := range sql.Fields {
+			fieldName, err := baseField(column)
+			if err != nil {
+				return sql, nil, err
+			}
+			rewritten.Fields = append(rewritten.Fields, fieldName)
+		}
+	case Update:
+		rewritten.Updates = map[string]*Expression{}
+		for column, expression := range sql.Updates {
+			fieldName, err := baseField(column)
+			if err != nil {
+				return sql, nil, err
+			}
+			rewritten.Updates[fieldName], err = expression.substituteFields(scope)
+			if err != nil {
+				return sql, nil, fmt.Errorf("at %s: %v", operation, err)
+			}
+		}
+	}
+	// 只能更新和删除视图中的行：语句的条件和视图的条件同时满足
+	conditions, err := substituteConditions(sql.Conditions, scope)
+	if err != nil {
+		return sql, nil, fmt.Errorf("at %s: %v", operation, err)
+	}
+	rewritten.Conditions, rewritten.ConditionOperators = conjoinConditions(conditions, sql.ConditionOperators,
+		view.Conditions, view.ConditionOperators)
+	return rewritten, view, nil
+}
+
+// 检查通过视图插入或者更新的行是否满足视图的WITH CHECK OPTION
+func (view *updatableView) checkRow(operation string, getValue valueGetter) error {
+	if view == nil || view.CheckView == "" {
+		return nil
+	}
+	matched, err := matchConditions(view.CheckConditions, view.CheckOperators, getValue)
+	if err != nil {
+		return fmt.Errorf("at %s: %v", operation, err)
+	}
+	if !matched {
+		return fmt.Errorf("at %s: row violates WITH CHECK OPTION of view %s", operation, view.CheckView)
+	}
+	return nil
+}
+
+// 返回用于读取一行待插入的数据的valueGetter，没有插入的列为空值
+func insertRowGetter(table *TableJson, fields []string, values []string) valueGetter {
+	return func(fieldName string) (value string, dataType DataType, err error) {
+		fieldName = strings.TrimPrefix(fieldName, table.Name+".")
+		for _, field := range table.Fields {
+			if field.Name == fieldName {
+				for index, insertField := range fields {
+					if insertField == fieldName && index < len(values) {
+						return values[index], field.DataType, nil
+					}
+				}
+				return NullValue, field.DataType, nil
+			}
+		}
+		return "", UnknownDataType, fmt.Errorf("at WHERE: unknown field %s in table %s", fieldName, table.Name)
+	}
+}
+
+// 把条件中引用的列替换为scope中对应的表达式
+func substituteConditions(conditions []Condition, scope map[string]*Expression) (substituted []Condition, err error) {
+	for _, condition := range conditions {
+		// 括号中的一组条件
+		if len(condition.Group) > 0 {
+			if condition.Group, err = substituteConditions(condition.Group, scope); err != nil {
+				return nil, err
+			}
+			substituted = append(substituted, condition)
+			continue
+		}
+		// EXISTS和NOT EXISTS没有左边的操作数
+		if condition.Operator != Exists && condition.Operator != NotExists {
+			left := conditionExpression(condition.Expression1, condition.Operand1, condition.Operand1IsField)
+			if condition.Expression1, err = left.substituteFields(scope); err != nil {
+				return nil, err
+			}
+		}
+		if condition.Expression2 != nil || condition.Operand2IsField {
+			right := conditionExpression(condition.Expression2, condition.Operand2, condition.Operand2IsField)
+			if condition.Expression2, err = right.substituteFields(scope); err != nil {
+				return nil, err
+			}
+		}
+		substituted = append(substituted, condition)
+	}
+	return substituted, nil
+}
+
+// 用AND合并两组条件：(A1 OR A2) AND (B1 OR B2)展开为A1 AND B1 OR A1 AND B2 OR A2 AND B1 OR A2 AND B2
+func conjoinConditions(conditions1 []Condition, operators1 []ConditionOperator,
+	conditions2 []Condition, operators2 []ConditionOperator) (conditions []Condition, operators []ConditionOperator) {
+	if len(conditions1) == 0 {
+		return conditions2, operators2
+	}
+	if len(conditions2) == 0 {
+		return conditions1, operators1
+	}
+	for _, group1 := range splitOrGroups(conditions1, operators1) {
+		for _, group2 := range splitOrGroups(conditions2, operators2) {
+			if len(conditions) > 0 {
+				operators = append(operators, Or)
+			}
+			for index, condition := range append(append([]Condition{}, group1...), group2...) {
+				if index > 0 {
+					operators = append(operators, And)
+				}
+				conditions = append(conditions, condition)
+			}
+		}
+	}
+	return conditions, operators
+}
+
+// 把条件按OR分成若干组，每一组内的条件用AND连接
+func splitOrGroups(conditions []Condition, operators []ConditionOperator) (groups [][]Condition) {
+	var group []Condition
+	for index, condition := range conditions {
+		group = append(group, condition)
+		if index >= len(operators) || operators[index] == Or {
+			groups = append(groups, group)
+			group = nil
+		}
+	}
+	return groups
+}
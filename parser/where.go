@@ -0,0 +1,333 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DateTime类型的存储格式
+const dateTimeLayout = "2006-01-02 15:04:05"
+
+// 根据列名取得当前行中该列的值和数据类型
+type valueGetter func(field string) (value string, dataType DataType, err error)
+
+// 筛选出表中满足Where子句的所有行，返回这些行的下标
+func filterTableRows(table *TableJson, conditions []Condition, operators []ConditionOperator) (rows []int, err error) {
+	if err := tableSchema(table).checkConditions(conditions); err != nil {
+		return nil, err
+	}
+	rows = []int{}
+	for row := 0; row < tableRowCount(table); row++ {
+		matched, err := matchConditions(conditions, operators, tableRowGetter(table, row))
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// 表中数据的行数，以数据最多的列为准
+func tableRowCount(table *TableJson) (count int) {
+	for _, field := range table.Fields {
+		if len(field.Data) > count {
+			count = len(field.Data)
+		}
+	}
+	return count
+}
+
+// 返回用于读取表中某一行数据的valueGetter
+func tableRowGetter(table *TableJson, row int) valueGetter {
+	return func(fieldName string) (value string, dataType DataType, err error) {
+		// 列名可以带上本表的表名
+		fieldName = strings.TrimPrefix(fieldName, table.Name+".")
+		for _, field := range table.Fields {
+			if field.Name == fieldName {
+				// 该列的数据比其他列少，缺少的部分视为空值
+				if row >= len(field.Data) {
+					return NullValue, field.DataType, nil
+				}
+				return field.Data[row], field.DataType, nil
+			}
+		}
+		return "", UnknownDataType, fmt.Errorf("at WHERE: unknown field %s in table %s", fieldName, table.Name)
+	}
+}
+
+// 三值逻辑中条件的结果：有空值参与比较时结果既不是真也不是假，而是未知
+type truth int
+
+const (
+	truthFalse   truth = iota // 假
+	truthUnknown              // 未知
+	truthTrue                 // 真
+)
+
+// 把布尔值转换为三值逻辑的结果
+func toTruth(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+// 判断一行数据是否满足Where子句的全部条件，只有结果为真的行才满足条件，结果未知的行不满足
+func matchConditions(conditions []Condition, operators []ConditionOperator, getValue valueGetter) (result bool, err error) {
+	value, err := conditionsTruth(conditions, operators, getValue)
+	return value == truthTrue, err
+}
+
+// 按照三值逻辑计算Where子句的全部条件：And取各个条件中最小的结果，Or取最大的结果，假 < 未知 < 真
+// And的优先级高于Or：先分别计算被Or分隔开的每一组And条件，再对各组的结果做或运算
+func conditionsTruth(conditions []Condition, operators []ConditionOperator, getValue valueGetter) (result truth, err error) {
+	// 没有Where子句，所有行都满足条件
+	if len(conditions) == 0 {
+		return truthTrue, nil
+	}
+	result, group := truthFalse, truthTrue
+	for index, condition := range conditions {
+		matched, err := conditionTruth(condition, getValue)
+		if err != nil {
+			return truthFalse, err
+		}
+		if matched < group {
+			group = matched
+		}
+		// 当前这一组And条件结束：后面是Or，或者已经是最后一个条件
+		if index >= len(operators) || operators[index] == Or {
+			if group > result {
+				result = group
+			}
+			group = truthTrue
+		}
+	}
+	return result, nil
+}
+
+// 按照三值逻辑计算一行数据上单个条件的结果，括号中的一组条件作为一个条件计算
+// NOT对结果取反：真变为假，假变为真，未知仍然是未知
+func conditionTruth(condition Condition, getValue valueGetter) (result truth, err error) {
+	if len(condition.Group) > 0 {
+		result, err = conditionsTruth(condition.Group, condition.GroupOperators, getValue)
+	} else {
+		result, err = predicateTruth(condition, getValue)
+	}
+	if err != nil {
+		return truthFalse, err
+	}
+	if condition.Not {
+		result = truthTrue - result
+	}
+	return result, nil
+}
+
+// 按照三值逻辑计算一行数据上单个比较条件的结果
+func predicateTruth(condition Condition, getValue valueGetter) (result truth, err error) {
+	// EXISTS：当前行作为外层查询的行执行子查询，判断子查询是否有结果
+	if condition.Operator == Exists || condition.Operator == NotExists {
+		set, err := selectResultSet(*condition.Subquery, getValue)
+		if err != nil {
+			return truthFalse, err
+		}
+		return toTruth((len(set.rows) > 0) != (condition.Operator == NotExists)), nil
+	}
+	left := conditionExpression(condition.Expression1, condition.Operand1, condition.Operand1IsField)
+	value, dataType, err := left.evaluate(getValue)
+	if err != nil {
+		return truthFalse, err
+	}
+	// IS NULL和IS NOT NULL的结果只可能是真或者假
+	if condition.Operator == IsNull || condition.Operator == IsNotNull {
+		return toTruth((value == NullValue) == (condition.Operator == IsNull)), nil
+	}
+	// 空值参与的其他比较结果都是未知
+	if value == NullValue {
+		return truthUnknown, nil
+	}
+
+	switch {
+	case condition.IsBetween || condition.IsNotBetween:
+		// Between-And：BetweenOperand1 <= 值 <= BetweenOperand2，上下界有空值时结果未知
+		if condition.BetweenOperand1 == NullValue || condition.BetweenOperand2 == NullValue {
+			return truthUnknown, nil
+		}
+		lower, err := compareValues(value, condition.BetweenOperand1, dataType)
+		if err != nil {
+			return truthFalse, err
+		}
+		upper, err := compareValues(value, condition.BetweenOperand2, dataType)
+		if err != nil {
+			return truthFalse, err
+		}
+		between := lower >= 0 && upper <= 0
+		return toTruth(between != condition.IsNotBetween), nil
+	case condition.IsIn || condition.IsNotIn:
+		// In：值等于In列表或者子查询结果中的任意一个
+		inValues, inType := condition.InConditions, dataType
+		if condition.Subquery != nil {
+			inValues, inType, err = subqueryValues(*condition.Subquery, getValue)
+			if err != nil {
+				return truthFalse, err
+			}
+			inType = comparisonDataType(dataType, inType)
+		}
+		in, hasNull := false, false
+		for _, inValue := range inValues {
+			if inValue == NullValue {
+				hasNull = true
+				continue
+			}
+			cmp, err := compareValues(value, inValue, inType)
+			if err != nil {
+				return truthFalse, err
+			}
+			if cmp == 0 {
+				in = true
+				break
+			}
+		}
+		// 没有找到相等的值，但是有空值时，结果是未知，IN和NOT IN都不满足
+		if !in && hasNull {
+			return truthUnknown, nil
+		}
+		return toTruth(in != condition.IsNotIn), nil
+	}
+
+	right := conditionExpression(condition.Expression2, condition.Operand2, condition.Operand2IsField)
+	operand, operandType, err := right.evaluate(getValue)
+	if err != nil {
+		return truthFalse, err
+	}
+	if operand == NullValue {
+		return truthUnknown, nil
+	}
+
+	switch condition.Operator {
+	case Like, NotLike:
+		// CHAR末尾补齐的空格不参与匹配
+		if dataType == Char {
+			value = strings.TrimRight(value, " ")
+		}
+		return toTruth(matchLike(value, operand) != (condition.Operator == NotLike)), nil
+	}
+
+	// 两边的类型不同时，例如SMALLINT的列与DOUBLE的表达式比较，按照共同的类型比较
+	cmp, err := compareValues(value, operand, comparisonDataType(dataType, operandType))
+	if err != nil {
+		return truthFalse, err
+	}
+	switch condition.Operator {
+	case Eq:
+		return toTruth(cmp == 0), nil
+	case Ne:
+		return toTruth(cmp != 0), nil
+	case Gt:
+		return toTruth(cmp > 0), nil
+	case Lt:
+		return toTruth(cmp < 0), nil
+	case Gte:
+		return toTruth(cmp >= 0), nil
+	case Lte:
+		return toTruth(cmp <= 0), nil
+	default:
+		return truthFalse, fmt.Errorf("at WHERE: unknown operator")
+	}
+}
+
+// 按照数据类型比较两个值，a < b返回负数，a == b返回0，a > b返回正数
+func compareValues(a string, b string, dataType DataType) (result int, err error) {
+	switch dataType {
+	case SmallInt:
+		x, errA := strconv.ParseInt(a, 10, 32)
+		y, errB := strconv.ParseInt(b, 10, 32)
+		if errA != nil || errB != nil {
+			return 0, fmt.Errorf("at WHERE: cannot compare %s and %s as SMALLINT", a, b)
+		}
+		return compareFloat(float64(x), float64(y)), nil
+	case Double:
+		x, errA := strconv.ParseFloat(a, 64)
+		y, errB := strconv.ParseFloat(b, 64)
+		if errA != nil || errB != nil {
+			return 0, fmt.Errorf("at WHERE: cannot compare %s and %s as DOUBLE", a, b)
+		}
+		return compareFloat(x, y), nil
+	case BigInt:
+		x, okA := new(big.Int).SetString(a, 10)
+		y, okB := new(big.Int).SetString(b, 10)
+		if !okA || !okB {
+			return 0, fmt.Errorf("at WHERE: cannot compare %s and %s as BIGINT", a, b)
+		}
+		return x.Cmp(y), nil
+	case Decimal:
+		// 按十进制精确比较
+		x, errA := parseDecimal(a)
+		y, errB := parseDecimal(b)
+		if errA != nil || errB != nil {
+			return 0, fmt.Errorf("at WHERE: cannot compare %s and %s as DECIMAL", a, b)
+		}
+		return x.Cmp(y), nil
+	case Char:
+		// 末尾补齐的空格不参与比较
+		return strings.Compare(strings.TrimRight(a, " "), strings.TrimRight(b, " ")), nil
+	case Boolean, Date, Time, Blob:
+		// 先转换为规范形式再比较：FALSE < TRUE，日期和时间的规范形式按字符串比较就是按时间先后比较，BLOB按字节比较
+		x, errA := convertValue(a, dataType, 0, 0)
+		y, errB := convertValue(b, dataType, 0, 0)
+		if errA != nil || errB != nil {
+			return 0, fmt.Errorf("at WHERE: cannot compare %s and %s as %s", a, b, DataTypeString[dataType])
+		}
+		return strings.Compare(x, y), nil
+	case DateTime:
+		// 只有日期的值视为当天的00:00:00
+		x, errA := toDateTime(DataTypeString[dataType], a)
+		y, errB := toDateTime(DataTypeString[dataType], b)
+		if errA != nil || errB != nil {
+			return 0, fmt.Errorf("at WHERE: cannot compare %s and %s as DATETIME", a, b)
+		}
+		if x.Before(y) {
+			return -1, nil
+		}
+		if x.After(y) {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return strings.Compare(a, b), nil
+	}
+}
+
+// 比较两个浮点数
+func compareFloat(a float64, b float64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// 判断值是否匹配Like的模式：%匹配任意个字符，_匹配单个字符
+func matchLike(value string, pattern string) bool {
+	var builder strings.Builder
+	builder.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			builder.WriteString(".*")
+		case '_':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	builder.WriteString("$")
+	matched, _ := regexp.MatchString("(?s)"+builder.String(), value)
+	return matched
+}